@@ -4,21 +4,57 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 )
 
 func NewNamespaceContext() *NamespaceContext {
 	context := &NamespaceContext{}
 	context.prefixToExpansionMappings = make(map[string]string)
 	context.expansionToPrefixMappings = make(map[string]string)
+	context.expansions = newExpansionTrie()
 	return context
 }
 
+// NamespaceContext is the inbuilt NamespaceManager. It is safe for concurrent use, so one context can be shared by
+// parsers and writers running in different goroutines.
 type NamespaceContext struct {
+	lock                      sync.RWMutex
 	prefixToExpansionMappings map[string]string
 	expansionToPrefixMappings map[string]string
+	expansions                *expansionTrie
+	preferWellKnownPrefixes   bool
+	// iriSchemes are the schemes recognised as IRIs in addition to the defaultIRISchemes
+	iriSchemes map[string]bool
+}
+
+// WithIRISchemes adds schemes that the context recognises as IRIs rather than CURIEs, so that for example values
+// starting with "isbn:" are not treated as CURIEs with the prefix isbn. The schemes only apply to this context.
+func (aContext *NamespaceContext) WithIRISchemes(schemes ...string) *NamespaceContext {
+	aContext.lock.Lock()
+	defer aContext.lock.Unlock()
+
+	if aContext.iriSchemes == nil {
+		aContext.iriSchemes = make(map[string]bool, len(schemes))
+	}
+	for _, scheme := range schemes {
+		aContext.iriSchemes[strings.ToLower(scheme)] = true
+	}
+	return aContext
+}
+
+func (aContext *NamespaceContext) isIRIScheme(scheme string) bool {
+	if defaultIRISchemes[scheme] {
+		return true
+	}
+	aContext.lock.RLock()
+	defer aContext.lock.RUnlock()
+	return aContext.iriSchemes[scheme]
 }
 
 func (aContext *NamespaceContext) AsContext() *Context {
+	aContext.lock.RLock()
+	defer aContext.lock.RUnlock()
+
 	context := NewContext()
 	for prefix, expansion := range aContext.prefixToExpansionMappings {
 		context.Namespaces[prefix] = expansion
@@ -28,59 +64,75 @@ func (aContext *NamespaceContext) AsContext() *Context {
 
 func (aContext *NamespaceContext) AssertPrefixedIdentifierFromURI(URI string) (string, error) {
 	// find last hash or slash
-	lastHash := strings.LastIndex(URI, "#")
-	if lastHash > 0 {
-		postfix := URI[lastHash+1:]
-		expansion := URI[:lastHash+1]
-		if postfix == "" {
-			return "", errors.New("unable to assert prefixed identifier from URI: " + URI + " - no postfix")
-		}
+	separator := strings.LastIndex(URI, "#")
+	if separator <= 0 {
+		separator = strings.LastIndex(URI, "/")
+	}
+	if separator <= 0 {
+		return "", errors.New("unable to assert prefix from URI: " + URI)
+	}
 
-		// check if expansion exists
-		if prefix, found := aContext.expansionToPrefixMappings[expansion]; found {
-			return prefix + ":" + postfix, nil
-		} else {
-			// generate new prefix
-			shortCode := fmt.Sprintf("ns%d", len(aContext.expansionToPrefixMappings))
-			// store prefix expansion mapping
-			aContext.StorePrefixExpansionMapping(shortCode, expansion)
+	postfix := URI[separator+1:]
+	expansion := URI[:separator+1]
+	if postfix == "" {
+		return "", errors.New("unable to assert prefixed identifier from URI: " + URI + " - no postfix")
+	}
 
-			return shortCode + ":" + postfix, nil
-		}
-	} else {
-		lastSlash := strings.LastIndex(URI, "/")
-		if lastSlash > 0 {
-			postfix := URI[lastSlash+1:]
-			expansion := URI[:lastSlash+1]
-			if postfix == "" {
-				return "", errors.New("unable to assert prefixed identifier from URI: " + URI + " - no postfix")
-			}
+	// check if expansion exists
+	aContext.lock.RLock()
+	prefix, found := aContext.expansionToPrefixMappings[expansion]
+	aContext.lock.RUnlock()
+	if found {
+		return prefix + ":" + postfix, nil
+	}
 
-			// check if expansion exists
-			if prefix, found := aContext.expansionToPrefixMappings[expansion]; found {
-				return prefix + ":" + postfix, nil
-			} else {
-				// generate new prefix
-				shortCode := fmt.Sprintf("ns%d", len(aContext.expansionToPrefixMappings))
+	// the check is repeated under the write lock so that concurrent callers share one generated prefix
+	aContext.lock.Lock()
+	defer aContext.lock.Unlock()
+	if prefix, found := aContext.expansionToPrefixMappings[expansion]; found {
+		return prefix + ":" + postfix, nil
+	}
 
-				// store prefix expansion mapping
-				aContext.StorePrefixExpansionMapping(shortCode, expansion)
-				return shortCode + ":" + postfix, nil
+	// generate new prefix
+	shortCode := aContext.nextGeneratedPrefix()
+	if aContext.preferWellKnownPrefixes {
+		if prefix, found := wellKnownPrefixForExpansion(expansion); found {
+			if _, used := aContext.prefixToExpansionMappings[prefix]; !used {
+				shortCode = prefix
 			}
 		}
 	}
-	return "", errors.New("unable to assert prefix from URI: " + URI)
+	// store prefix expansion mapping
+	aContext.storePrefixExpansionMapping(shortCode, expansion)
+	return shortCode + ":" + postfix, nil
+}
+
+// nextGeneratedPrefix returns the first unused nsN prefix, starting from the number of expansions. Must be called
+// with the write lock held.
+func (aContext *NamespaceContext) nextGeneratedPrefix() string {
+	for i := len(aContext.expansionToPrefixMappings); ; i++ {
+		shortCode := fmt.Sprintf("ns%d", i)
+		if _, used := aContext.prefixToExpansionMappings[shortCode]; !used {
+			return shortCode
+		}
+	}
 }
 
 func (aContext *NamespaceContext) GetNamespaceExpansionForPrefix(prefix string) (string, error) {
+	aContext.lock.RLock()
+	defer aContext.lock.RUnlock()
+
 	if expansion, found := aContext.prefixToExpansionMappings[prefix]; found {
 		return expansion, nil
 	} else {
-		return "", errors.New("no expansion for prefix: " + prefix)
+		return "", fmt.Errorf("%w: %s", ErrMissingPrefix, prefix)
 	}
 }
 
 func (aContext *NamespaceContext) GetPrefixForExpansion(expansion string) (string, error) {
+	aContext.lock.RLock()
+	defer aContext.lock.RUnlock()
+
 	if prefix, found := aContext.expansionToPrefixMappings[expansion]; found {
 		return prefix, nil
 	} else {
@@ -89,12 +141,82 @@ func (aContext *NamespaceContext) GetPrefixForExpansion(expansion string) (strin
 }
 
 func (aContext *NamespaceContext) StorePrefixExpansionMapping(prefix string, expansion string) {
+	aContext.lock.Lock()
+	defer aContext.lock.Unlock()
+
+	aContext.storePrefixExpansionMapping(prefix, expansion)
+}
+
+// StorePrefixExpansionMappingWithPolicy stores the mapping and resolves a conflict with an existing binding of the
+// prefix according to the policy. The returned conflict is nil if the prefix was unbound or bound to the same expansion.
+func (aContext *NamespaceContext) StorePrefixExpansionMappingWithPolicy(prefix string, expansion string, policy PrefixConflictPolicy) (*PrefixConflict, error) {
+	aContext.lock.Lock()
+	defer aContext.lock.Unlock()
+
+	existing, found := aContext.prefixToExpansionMappings[prefix]
+	if !found || existing == expansion {
+		aContext.storePrefixExpansionMapping(prefix, expansion)
+		return nil, nil
+	}
+
+	conflict := &PrefixConflict{Prefix: prefix, Expansion: expansion, ExistingExpansion: existing}
+	switch policy {
+	case PrefixConflictError:
+		return conflict, newPrefixConflictError(conflict)
+	case PrefixConflictKeepFirst:
+		return conflict, nil
+	case PrefixConflictRename:
+		if bound, found := aContext.expansionToPrefixMappings[expansion]; found {
+			conflict.ResolvedPrefix = bound
+			return conflict, nil
+		}
+		conflict.ResolvedPrefix = freshPrefix(prefix, func(prefix string) bool {
+			_, used := aContext.prefixToExpansionMappings[prefix]
+			return used
+		})
+		aContext.storePrefixExpansionMapping(conflict.ResolvedPrefix, expansion)
+		return conflict, nil
+	}
+
+	aContext.storePrefixExpansionMapping(prefix, expansion)
+	conflict.ResolvedPrefix = prefix
+	return conflict, nil
+}
+
+// storePrefixExpansionMapping stores the mapping, must be called with the write lock held. When the prefix is rebound
+// the previous expansion is moved to another prefix bound to it, or removed, so that the two maps agree.
+func (aContext *NamespaceContext) storePrefixExpansionMapping(prefix string, expansion string) {
+	if aContext.prefixToExpansionMappings == nil {
+		aContext.prefixToExpansionMappings = make(map[string]string)
+		aContext.expansionToPrefixMappings = make(map[string]string)
+	}
+	if aContext.expansions == nil {
+		aContext.expansions = newExpansionTrie()
+	}
+
+	if previous, found := aContext.prefixToExpansionMappings[prefix]; found && previous != expansion {
+		aContext.prefixToExpansionMappings[prefix] = expansion
+		if aContext.expansionToPrefixMappings[previous] == prefix {
+			delete(aContext.expansionToPrefixMappings, previous)
+			for otherPrefix, otherExpansion := range aContext.prefixToExpansionMappings {
+				if otherExpansion == previous {
+					aContext.expansionToPrefixMappings[previous] = otherPrefix
+					aContext.expansions.insert(previous, otherPrefix)
+					break
+				}
+			}
+		}
+	}
+
 	aContext.prefixToExpansionMappings[prefix] = expansion
 	aContext.expansionToPrefixMappings[expansion] = prefix
+	aContext.expansions.insert(expansion, prefix)
 }
 
+// IsFullUri returns true if the value is an IRI rather than a CURIE. Values with an IRI scheme of the context, such as
+// urn: or mailto:, are IRIs unless the scheme is declared as a prefix in the context.
 func (aContext *NamespaceContext) IsFullUri(value string) bool {
-	return strings.HasPrefix(value, "https:") || strings.HasPrefix(value, "http:")
+	return classifyIRI(value, aContext.isIRIScheme, aContext.DoesExpansionExistForPrefix)
 }
 
 func (aContext *NamespaceContext) isCURIE(value string) (bool, string, string) {
@@ -132,17 +254,31 @@ func (aContext *NamespaceContext) GetFullURI(value string) (string, error) {
 	}
 }
 
-// implement get namespace mappings
+// implement get namespace mappings, the returned map is a copy of the mappings of the context
 func (aContext *NamespaceContext) GetNamespaceMappings() map[string]string {
-	return aContext.prefixToExpansionMappings
+	aContext.lock.RLock()
+	defer aContext.lock.RUnlock()
+
+	mappings := make(map[string]string, len(aContext.prefixToExpansionMappings))
+	for prefix, expansion := range aContext.prefixToExpansionMappings {
+		mappings[prefix] = expansion
+	}
+	return mappings
 }
 
-// implement get prefixed identifier
+// implement get prefixed identifier, the prefix of the longest matching expansion is used
 func (aContext *NamespaceContext) GetPrefixedIdentifier(value string) (string, error) {
 	if aContext.IsFullUri(value) {
-		for prefix, expansion := range aContext.prefixToExpansionMappings {
-			if strings.HasPrefix(value, expansion) {
-				return prefix + ":" + strings.TrimPrefix(value, expansion), nil
+		aContext.lock.RLock()
+		defer aContext.lock.RUnlock()
+
+		if aContext.expansions != nil {
+			// an expansion is only used while its prefix is still mapped to it
+			expansion, prefix, found := aContext.expansions.longestMatch(value, func(expansion string, prefix string) bool {
+				return aContext.prefixToExpansionMappings[prefix] == expansion
+			})
+			if found {
+				return prefix + ":" + value[len(expansion):], nil
 			}
 		}
 		return "", errors.New("unable to find prefix for expansion: " + value)
@@ -152,6 +288,9 @@ func (aContext *NamespaceContext) GetPrefixedIdentifier(value string) (string, e
 }
 
 func (aContext *NamespaceContext) DoesExpansionExistForPrefix(prefix string) bool {
+	aContext.lock.RLock()
+	defer aContext.lock.RUnlock()
+
 	_, found := aContext.prefixToExpansionMappings[prefix]
 	return found
 }
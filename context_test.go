@@ -0,0 +1,288 @@
+package egdm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAssertPrefixedIdentifierConcurrently(t *testing.T) {
+	nsManager := NewNamespaceContext()
+
+	workers := 16
+	expansions := 50
+	results := make([][]string, workers)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < expansions; i++ {
+				id, err := nsManager.AssertPrefixedIdentifierFromURI(fmt.Sprintf("http://data.example.com/%d/thing", i))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				results[w] = append(results[w], id)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// every worker must see the same prefix for an expansion and every expansion must have its own prefix
+	mappings := nsManager.GetNamespaceMappings()
+	if len(mappings) != expansions {
+		t.Fatalf("expected %d prefixes, got %d", expansions, len(mappings))
+	}
+	for w := 1; w < workers; w++ {
+		for i := range results[w] {
+			if results[w][i] != results[0][i] {
+				t.Errorf("expected %s, got %s", results[0][i], results[w][i])
+			}
+		}
+	}
+	for i, id := range results[0] {
+		fullURI, err := nsManager.GetFullURI(id)
+		if err != nil || fullURI != fmt.Sprintf("http://data.example.com/%d/thing", i) {
+			t.Errorf("unexpected full uri %s for %s %v", fullURI, id, err)
+		}
+	}
+}
+
+func TestAssertPrefixedIdentifierSkipsUsedPrefix(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ns1", "http://data.example.com/a/")
+
+	id, err := nsManager.AssertPrefixedIdentifierFromURI("http://data.example.com/b/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "ns2:1" {
+		t.Errorf("expected ns2:1, got %s", id)
+	}
+}
+
+func TestGetNamespaceMappingsReturnsCopy(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	mappings := nsManager.GetNamespaceMappings()
+	mappings["other"] = "http://other.example.com/"
+	if nsManager.DoesExpansionExistForPrefix("other") {
+		t.Errorf("expected changes to the returned mappings not to change the context")
+	}
+}
+
+func TestParseAndWriteConcurrently(t *testing.T) {
+	nsManager := NewNamespaceContext()
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < 16; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			data := fmt.Sprintf(`[
+				{"id":"@context","namespaces":{"ex%d":"http://data.example.com/%d/"}},
+				{"id":"http://data.example.com/%d/1","props":{"http://data.example.com/shared/name":"thing"},"refs":{"http://data.example.com/%d/type/kind":"http://data.example.com/%d/2"}}
+			]`, w, w, w, w, w)
+			ec, err := NewEntityParser(nsManager).WithCompressURIs().LoadEntityCollection(bytes.NewReader([]byte(data)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			buffer := bytes.Buffer{}
+			if err := ec.WriteEntityGraphJSON(&buffer); err != nil {
+				t.Error(err)
+				return
+			}
+			result, err := NewEntityParser(NewNamespaceContext()).WithExpandURIs().LoadEntityCollection(&buffer)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if result.Entities[0].ID != fmt.Sprintf("http://data.example.com/%d/1", w) {
+				t.Errorf("unexpected id %s", result.Entities[0].ID)
+			}
+			if result.Entities[0].Properties["http://data.example.com/shared/name"] != "thing" {
+				t.Errorf("unexpected properties %v", result.Entities[0].Properties)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestGetPrefixedIdentifierLongestMatch(t *testing.T) {
+	testGetPrefixedIdentifierLongestMatch(t, []string{"ex", "people", "staff"})
+	testGetPrefixedIdentifierLongestMatch(t, []string{"staff", "people", "ex"})
+}
+
+func testGetPrefixedIdentifierLongestMatch(t *testing.T, order []string) {
+	expansions := map[string]string{
+		"ex":     "http://ex.com/",
+		"people": "http://ex.com/people/",
+		"staff":  "http://ex.com/people/staff/",
+	}
+	nsManager := NewNamespaceContext()
+	for _, prefix := range order {
+		nsManager.StorePrefixExpansionMapping(prefix, expansions[prefix])
+	}
+
+	// repeated to catch a lookup that depends on map iteration order
+	for i := 0; i < 100; i++ {
+		for uri, expected := range map[string]string{
+			"http://ex.com/1":                 "ex:1",
+			"http://ex.com/people/1":          "people:1",
+			"http://ex.com/people/staff/1":    "staff:1",
+			"http://ex.com/people/staffing/1": "people:staffing/1",
+		} {
+			id, err := nsManager.GetPrefixedIdentifier(uri)
+			if err != nil || id != expected {
+				t.Fatalf("expected %s for %s, got %s %v", expected, uri, id, err)
+			}
+		}
+	}
+
+	if _, err := nsManager.GetPrefixedIdentifier("http://other.com/1"); err == nil {
+		t.Errorf("expected error for uri without a matching expansion")
+	}
+}
+
+func TestGetPrefixedIdentifierAfterPrefixIsRemapped(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://ex.com/")
+	nsManager.StorePrefixExpansionMapping("people", "http://ex.com/people/")
+	nsManager.StorePrefixExpansionMapping("people", "http://people.com/")
+
+	id, err := nsManager.GetPrefixedIdentifier("http://ex.com/people/1")
+	if err != nil || id != "ex:people/1" {
+		t.Errorf("expected ex:people/1, got %s %v", id, err)
+	}
+	id, err = nsManager.GetPrefixedIdentifier("http://people.com/1")
+	if err != nil || id != "people:1" {
+		t.Errorf("expected people:1, got %s %v", id, err)
+	}
+}
+
+func benchmarkNamespaceContext(namespaces int) (*NamespaceContext, []string) {
+	nsManager := NewNamespaceContext()
+	uris := make([]string, 0, namespaces)
+	for i := 0; i < namespaces; i++ {
+		expansion := fmt.Sprintf("http://data.example.com/domain%d/type%d/", i%50, i)
+		nsManager.StorePrefixExpansionMapping(fmt.Sprintf("p%d", i), expansion)
+		uris = append(uris, expansion+"entity1")
+	}
+	return nsManager, uris
+}
+
+// linearScanPrefixedIdentifier is the lookup GetPrefixedIdentifier used before the expansion trie
+func linearScanPrefixedIdentifier(mappings map[string]string, value string) (string, bool) {
+	for prefix, expansion := range mappings {
+		if strings.HasPrefix(value, expansion) {
+			return prefix + ":" + strings.TrimPrefix(value, expansion), true
+		}
+	}
+	return "", false
+}
+
+func BenchmarkGetPrefixedIdentifier(b *testing.B) {
+	for _, namespaces := range []int{10, 1000, 10000} {
+		nsManager, uris := benchmarkNamespaceContext(namespaces)
+		b.Run(fmt.Sprintf("trie/%d", namespaces), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := nsManager.GetPrefixedIdentifier(uris[i%len(uris)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		mappings := nsManager.GetNamespaceMappings()
+		b.Run(fmt.Sprintf("linear/%d", namespaces), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, found := linearScanPrefixedIdentifier(mappings, uris[i%len(uris)]); !found {
+					b.Fatal("no prefix found")
+				}
+			}
+		})
+	}
+}
+
+func TestIsFullUriWithIRISchemes(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	nsManager.StorePrefixExpansionMapping("tag", "http://example.com/tags/")
+
+	for value, expected := range map[string]bool{
+		"http://example.com/1":                          true,
+		"HTTPS://example.com/1":                         true,
+		"urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66": true,
+		"did:example:123456789abcdefghi":                true,
+		"mailto:john@example.com":                       true,
+		"ssh://example.com/repo":                        true,
+		"ex:1":                                          false,
+		"1":                                             false,
+		// tag is declared as a prefix so the value is a CURIE
+		"tag:red": false,
+		// isbn is not a registered scheme
+		"isbn:0451450523": false,
+	} {
+		if nsManager.IsFullUri(value) != expected {
+			t.Errorf("expected IsFullUri(%s) to be %v", value, expected)
+		}
+	}
+
+	fullURI, err := nsManager.GetFullURI("urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66")
+	if err != nil || fullURI != "urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66" {
+		t.Errorf("expected urn to be returned as it is, got %s %v", fullURI, err)
+	}
+	fullURI, err = nsManager.GetFullURI("tag:red")
+	if err != nil || fullURI != "http://example.com/tags/red" {
+		t.Errorf("expected declared prefix to be expanded, got %s %v", fullURI, err)
+	}
+}
+
+func TestNamespaceContextWithIRISchemes(t *testing.T) {
+	nsManager := NewNamespaceContext().WithIRISchemes("ISBN")
+	if !nsManager.IsFullUri("isbn:0451450523") {
+		t.Errorf("expected added scheme to be an IRI")
+	}
+
+	// the schemes of one context do not affect other contexts
+	if NewNamespaceContext().IsFullUri("isbn:0451450523") {
+		t.Errorf("expected scheme of another context to be a CURIE")
+	}
+}
+
+func TestNamespaceContextWithDefaults(t *testing.T) {
+	nsManager := NewNamespaceContextWithDefaults()
+	if expansion, _ := nsManager.GetNamespaceExpansionForPrefix("skos"); expansion != WellKnownPrefixes["skos"] {
+		t.Errorf("expected skos to be preloaded, got %s", expansion)
+	}
+	if id, _ := nsManager.AssertPrefixedIdentifierFromURI("http://www.w3.org/2004/02/skos/core#Concept"); id != "skos:Concept" {
+		t.Errorf("expected skos:Concept, got %s", id)
+	}
+}
+
+func TestNamespaceContextWithWellKnownPrefixPreference(t *testing.T) {
+	// the conventional prefix is used for well known expansions that are not stored
+	nsManager := NewNamespaceContext().WithWellKnownPrefixPreference()
+	if id, _ := nsManager.AssertPrefixedIdentifierFromURI("http://xmlns.com/foaf/0.1/name"); id != "foaf:name" {
+		t.Errorf("expected foaf:name, got %s", id)
+	}
+	if _, err := nsManager.GetNamespaceExpansionForPrefix("skos"); err == nil {
+		t.Errorf("expected well known prefixes that are not used to not be stored")
+	}
+
+	// a well known prefix bound to another expansion is not reused
+	nsManager.StorePrefixExpansionMapping("owl", "http://example.com/")
+	if id, _ := nsManager.AssertPrefixedIdentifierFromURI("http://www.w3.org/2002/07/owl#Class"); strings.HasPrefix(id, "owl:") {
+		t.Errorf("expected a generated prefix, got %s", id)
+	}
+
+	// the default context still generates prefixes
+	if id, _ := NewNamespaceContext().AssertPrefixedIdentifierFromURI("http://xmlns.com/foaf/0.1/name"); id != "ns0:name" {
+		t.Errorf("expected ns0:name, got %s", id)
+	}
+}
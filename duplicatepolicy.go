@@ -0,0 +1,150 @@
+package egdm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// DuplicatePolicy decides what happens when an entity is added to an EntityCollection that already has an entity with
+// the same id. A deleted entity is a version like any other, so a tombstone replaces the existing entity when the
+// policy would keep the new version.
+type DuplicatePolicy int
+
+const (
+	// DuplicateAppend adds every entity to the collection, this is the default
+	DuplicateAppend DuplicatePolicy = iota
+	// DuplicateReplace replaces the existing entity with the new entity
+	DuplicateReplace
+	// DuplicateKeepNewest keeps the entity with the highest Recorded value, the new entity wins if they are equal
+	DuplicateKeepNewest
+	// DuplicateKeepFirst keeps the existing entity and ignores the new entity
+	DuplicateKeepFirst
+	// DuplicateMerge merges the properties and references of the new entity into the existing entity, keys with
+	// different values become multi-valued. When either entity is a tombstone they are not merged, the one with the
+	// highest Recorded value is kept as with DuplicateKeepNewest.
+	DuplicateMerge
+	// DuplicateError rejects the new entity with an error wrapping ErrDuplicateEntity
+	DuplicateError
+)
+
+// ErrDuplicateEntity is wrapped by the error returned when an entity is added with the DuplicateError policy and the
+// collection already has an entity with the same id
+var ErrDuplicateEntity = errors.New("duplicate entity")
+
+// resolveDuplicate returns the entity that is kept in the collection when the entity is added and the collection has
+// the existing entity with the same id
+func resolveDuplicate(existing *Entity, entity *Entity, policy DuplicatePolicy) (*Entity, error) {
+	switch policy {
+	case DuplicateReplace:
+		return entity, nil
+	case DuplicateKeepNewest:
+		if existing.Recorded > entity.Recorded {
+			return existing, nil
+		}
+		return entity, nil
+	case DuplicateKeepFirst:
+		return existing, nil
+	case DuplicateMerge:
+		if existing.IsDeleted || entity.IsDeleted {
+			return resolveDuplicate(existing, entity, DuplicateKeepNewest)
+		}
+		return mergeEntities(existing, entity), nil
+	case DuplicateError:
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateEntity, entity.ID)
+	}
+	return nil, fmt.Errorf("unknown duplicate policy %d", policy)
+}
+
+// mergeEntities returns a new entity with the properties and references of both entities. The entities are not changed.
+func mergeEntities(existing *Entity, entity *Entity) *Entity {
+	result := NewEntity()
+	result.ID = existing.ID
+	result.InternalID = existing.InternalID
+	if result.InternalID == 0 {
+		result.InternalID = entity.InternalID
+	}
+	result.Recorded = max(existing.Recorded, entity.Recorded)
+
+	// extensions are not multi-valued, the value of the new entity wins
+	for _, extensions := range []map[string]any{existing.Extensions, entity.Extensions} {
+		for key, value := range extensions {
+			if result.Extensions == nil {
+				result.Extensions = make(map[string]any)
+			}
+			result.Extensions[key] = value
+		}
+	}
+
+	for key, value := range existing.Properties {
+		result.Properties[key] = value
+	}
+	for key, value := range entity.Properties {
+		if existingValue, found := result.Properties[key]; found {
+			value = mergeValues(existingValue, value, false)
+		}
+		result.Properties[key] = value
+	}
+
+	for key, value := range existing.References {
+		result.References[key] = value
+	}
+	for key, value := range entity.References {
+		if existingValue, found := result.References[key]; found {
+			value = mergeValues(existingValue, value, true)
+		}
+		result.References[key] = value
+	}
+
+	return result
+}
+
+// mergeValues returns the distinct values of both values. A single value is returned as it is, several values are
+// returned as []any, or as []string for references that are all strings.
+func mergeValues(existing any, value any, isReference bool) any {
+	values := appendDistinctValues(nil, existing)
+	values = appendDistinctValues(values, value)
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	if isReference {
+		refs := make([]string, 0, len(values))
+		for _, v := range values {
+			ref, ok := v.(string)
+			if !ok {
+				return values
+			}
+			refs = append(refs, ref)
+		}
+		return refs
+	}
+	return values
+}
+
+func appendDistinctValues(values []any, value any) []any {
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			values = appendDistinctValues(values, item)
+		}
+		return values
+	case []string:
+		for _, item := range v {
+			values = appendDistinctValues(values, item)
+		}
+		return values
+	case []*Entity:
+		for _, item := range v {
+			values = appendDistinctValues(values, item)
+		}
+		return values
+	}
+
+	for _, existing := range values {
+		if reflect.DeepEqual(existing, value) {
+			return values
+		}
+	}
+	return append(values, value)
+}
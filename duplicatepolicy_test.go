@@ -0,0 +1,135 @@
+package egdm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAddEntityWithDuplicatePolicy(t *testing.T) {
+	newCollection := func(policy DuplicatePolicy) *EntityCollection {
+		nsManager := NewNamespaceContext()
+		nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+		ec := NewEntityCollection(nsManager)
+		ec.SetDuplicatePolicy(policy)
+		return ec
+	}
+	version := func(id string, recorded uint64, name string) *Entity {
+		entity := NewEntity().SetID(id).SetProperty("ex:name", name)
+		entity.Recorded = recorded
+		return entity
+	}
+
+	ec := newCollection(DuplicateAppend)
+	_ = ec.AddEntity(version("ex:1", 1, "a"))
+	_ = ec.AddEntity(version("ex:1", 2, "b"))
+	if len(ec.Entities) != 2 {
+		t.Errorf("expected entities to be appended, got %d", len(ec.Entities))
+	}
+
+	ec = newCollection(DuplicateReplace)
+	_ = ec.AddEntity(version("ex:1", 2, "a"))
+	_ = ec.AddEntity(version("ex:2", 1, "x"))
+	_ = ec.AddEntity(version("http://example.com/1", 1, "b"))
+	if len(ec.Entities) != 2 || ec.Entities[0].Properties["ex:name"] != "b" {
+		t.Errorf("expected entity to be replaced in place, got %v", ec.Entities[0].Properties)
+	}
+
+	ec = newCollection(DuplicateKeepNewest)
+	_ = ec.AddEntity(version("ex:1", 2, "a"))
+	_ = ec.AddEntity(version("ex:1", 1, "b"))
+	if ec.GetEntity("ex:1").Properties["ex:name"] != "a" {
+		t.Errorf("expected the newest version to be kept")
+	}
+	tombstone := version("ex:1", 3, "c")
+	tombstone.IsDeleted = true
+	_ = ec.AddEntity(tombstone)
+	if !ec.GetEntity("ex:1").IsDeleted {
+		t.Errorf("expected the newer tombstone to be kept")
+	}
+
+	ec = newCollection(DuplicateKeepFirst)
+	_ = ec.AddEntity(version("ex:1", 1, "a"))
+	_ = ec.AddEntity(tombstone)
+	if ec.GetEntity("ex:1").IsDeleted || len(ec.Entities) != 1 {
+		t.Errorf("expected the first version to be kept")
+	}
+
+	ec = newCollection(DuplicateError)
+	_ = ec.AddEntity(version("ex:1", 1, "a"))
+	if err := ec.AddEntity(version("ex:1", 2, "b")); !errors.Is(err, ErrDuplicateEntity) {
+		t.Errorf("expected duplicate entity error, got %v", err)
+	}
+	if err := ec.AddEntity(version("ex:2", 2, "b")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestAddEntityWithDuplicateMerge(t *testing.T) {
+	ec := NewEntityCollection(nil)
+	ec.SetDuplicatePolicy(DuplicateMerge)
+
+	first := NewEntity().SetID("ex:1").SetProperty("ex:name", "a").SetProperty("ex:age", 1).SetReference("ex:knows", "ex:2")
+	first.Recorded = 2
+	second := NewEntity().SetID("ex:1").SetProperty("ex:name", "b").SetProperty("ex:age", 1).SetReference("ex:knows", []string{"ex:2", "ex:3"})
+	second.Recorded = 1
+	_ = ec.AddEntity(first)
+	_ = ec.AddEntity(second)
+
+	merged := ec.Entities[0]
+	if len(ec.Entities) != 1 || merged.Recorded != 2 {
+		t.Fatalf("unexpected merge result %v", ec.Entities)
+	}
+	if !reflect.DeepEqual(merged.Properties["ex:name"], []any{"a", "b"}) || merged.Properties["ex:age"] != 1 {
+		t.Errorf("unexpected merged properties %v", merged.Properties)
+	}
+	if !reflect.DeepEqual(merged.References["ex:knows"], []string{"ex:2", "ex:3"}) {
+		t.Errorf("unexpected merged references %v", merged.References)
+	}
+	if first.Properties["ex:name"] != "a" {
+		t.Errorf("expected the added entities not to be changed")
+	}
+
+	// a newer tombstone replaces the merged entity and a newer version replaces the tombstone
+	tombstone := NewEntity().SetID("ex:1")
+	tombstone.IsDeleted = true
+	tombstone.Recorded = 3
+	_ = ec.AddEntity(tombstone)
+	if !ec.Entities[0].IsDeleted {
+		t.Errorf("expected tombstone to win")
+	}
+	later := NewEntity().SetID("ex:1").SetProperty("ex:name", "c")
+	later.Recorded = 4
+	_ = ec.AddEntity(later)
+	if ec.Entities[0].IsDeleted || ec.Entities[0].Properties["ex:name"] != "c" {
+		t.Errorf("expected entity after tombstone to replace it, got %v", ec.Entities[0])
+	}
+}
+
+func TestAddEntityWithDuplicateMergeOutOfOrder(t *testing.T) {
+	ec := NewEntityCollection(nil)
+	ec.SetDuplicatePolicy(DuplicateMerge)
+
+	tombstone := NewEntity().SetID("ex:1")
+	tombstone.IsDeleted = true
+	tombstone.Recorded = 200
+	older := NewEntity().SetID("ex:1").SetProperty("ex:name", "a")
+	older.Recorded = 100
+
+	// an older version added after the tombstone does not bring the entity back
+	_ = ec.AddEntity(tombstone)
+	_ = ec.AddEntity(older)
+	if len(ec.Entities) != 1 || !ec.Entities[0].IsDeleted || ec.Entities[0].Recorded != 200 {
+		t.Errorf("expected the newer tombstone to be kept, got %+v", ec.Entities[0])
+	}
+
+	// an older tombstone added after a version does not delete it
+	ec = NewEntityCollection(nil)
+	ec.SetDuplicatePolicy(DuplicateMerge)
+	tombstone.Recorded = 50
+	_ = ec.AddEntity(older)
+	_ = ec.AddEntity(tombstone)
+	if ec.Entities[0].IsDeleted || ec.Entities[0].Recorded != 100 {
+		t.Errorf("expected the newer version to be kept, got %+v", ec.Entities[0])
+	}
+}
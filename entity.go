@@ -1,7 +1,13 @@
 package egdm
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
 )
 
 type Entity struct {
@@ -11,6 +17,42 @@ type Entity struct {
 	IsDeleted  bool           `json:"deleted,omitempty"`
 	References map[string]any `json:"refs"`
 	Properties map[string]any `json:"props"`
+	// Extensions holds keys of the entity object other than the ones above, they are written after the other keys.
+	// The EntityParser keeps unknown keys here when it is configured WithPreservedKeys.
+	Extensions map[string]any `json:"-"`
+}
+
+// MarshalJSON writes the entity with the keys of its Extensions. Extensions with the name of an entity key are not
+// written. It has a value receiver so that Entity values, such as the elements of a []Entity, keep their extensions.
+func (anEntity Entity) MarshalJSON() ([]byte, error) {
+	// entityJSON has the fields of Entity without its methods
+	type entityJSON Entity
+	data, err := json.Marshal(entityJSON(anEntity))
+	if err != nil || len(anEntity.Extensions) == 0 {
+		return data, err
+	}
+
+	buffer := bytes.NewBuffer(data[:len(data)-1])
+	for _, key := range sortedKeys(anEntity.Extensions) {
+		switch key {
+		case "id", "internalId", "recorded", "deleted", "refs", "props":
+			continue
+		}
+		keyJSON, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueJSON, err := json.Marshal(anEntity.Extensions[key])
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(',')
+		buffer.Write(keyJSON)
+		buffer.WriteByte(':')
+		buffer.Write(valueJSON)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
 }
 
 func NewEntity() *Entity {
@@ -50,6 +92,14 @@ func (anEntity *Entity) GetReferenceValues(typeURI string) ([]string, error) {
 		switch v := values.(type) {
 		case []string:
 			return v, nil
+		case []any:
+			result := make([]string, 0, len(v))
+			for _, val := range v {
+				if strVal, ok := val.(string); ok {
+					result = append(result, strVal)
+				}
+			}
+			return result, nil
 		case string:
 			result := make([]string, 1)
 			result[0] = v
@@ -131,20 +181,34 @@ func (anEntity *Entity) GetIntPropertyValues(typeURI string) ([]int, error) {
 		switch v := values.(type) {
 		case []int:
 			return v, nil
-		case int:
-			result := make([]int, 1)
-			result[0] = v
+		case []int64:
+			result := make([]int, 0, len(v))
+			for _, val := range v {
+				if intVal, ok := toInt(val); ok {
+					result = append(result, intVal)
+				}
+			}
 			return result, nil
 		case []float64:
-			result := make([]int, len(v))
-			for i, val := range v {
-				result[i] = int(val)
+			result := make([]int, 0, len(v))
+			for _, val := range v {
+				if intVal, ok := toInt(val); ok {
+					result = append(result, intVal)
+				}
 			}
 			return result, nil
-		case float64:
-			result := make([]int, 1)
-			result[0] = int(v)
+		case []any:
+			result := make([]int, 0, len(v))
+			for _, val := range v {
+				if intVal, ok := toInt(val); ok {
+					result = append(result, intVal)
+				}
+			}
 			return result, nil
+		default:
+			if intVal, ok := toInt(v); ok {
+				return []int{intVal}, nil
+			}
 		}
 	}
 	return nil, errors.New("no property int32 literal")
@@ -165,11 +229,250 @@ func (anEntity *Entity) GetFloatPropertyValues(typeURI string) ([]float64, error
 		switch v := values.(type) {
 		case []float64:
 			return v, nil
-		case float64:
-			result := make([]float64, 1)
-			result[0] = v
+		case []any:
+			result := make([]float64, 0, len(v))
+			for _, val := range v {
+				if floatVal, ok := toFloat(val); ok {
+					result = append(result, floatVal)
+				}
+			}
 			return result, nil
+		default:
+			if floatVal, ok := toFloat(v); ok {
+				return []float64{floatVal}, nil
+			}
 		}
 	}
 	return nil, errors.New("no property int32 literal")
 }
+
+func (anEntity *Entity) GetFirstDateTimePropertyValue(typeURI string) (time.Time, error) {
+	if values, found := anEntity.GetDateTimePropertyValues(typeURI); found == nil {
+		if len(values) == 0 {
+			return time.Time{}, errors.New("no reference for type")
+		}
+		return values[0], nil
+	}
+	return time.Time{}, errors.New("no reference for type")
+}
+
+// GetDateTimePropertyValues returns the DateTime, Date and time.Time values of the property
+func (anEntity *Entity) GetDateTimePropertyValues(typeURI string) ([]time.Time, error) {
+	if values, found := anEntity.Properties[typeURI]; found {
+		switch v := values.(type) {
+		case []any:
+			result := make([]time.Time, 0, len(v))
+			for _, val := range v {
+				if timeVal, ok := toTime(val); ok {
+					result = append(result, timeVal)
+				}
+			}
+			return result, nil
+		case []DateTime:
+			result := make([]time.Time, len(v))
+			for i, val := range v {
+				result[i] = val.Time
+			}
+			return result, nil
+		default:
+			if timeVal, ok := toTime(v); ok {
+				return []time.Time{timeVal}, nil
+			}
+		}
+	}
+	return nil, errors.New("no property dateTime literal")
+}
+
+func (anEntity *Entity) GetFirstDecimalPropertyValue(typeURI string) (Decimal, error) {
+	if values, found := anEntity.GetDecimalPropertyValues(typeURI); found == nil {
+		if len(values) == 0 {
+			return "", errors.New("no reference for type")
+		}
+		return values[0], nil
+	}
+	return "", errors.New("no reference for type")
+}
+
+func (anEntity *Entity) GetDecimalPropertyValues(typeURI string) ([]Decimal, error) {
+	if values, found := anEntity.Properties[typeURI]; found {
+		switch v := values.(type) {
+		case []any:
+			result := make([]Decimal, 0, len(v))
+			for _, val := range v {
+				if decimalVal, ok := val.(Decimal); ok {
+					result = append(result, decimalVal)
+				}
+			}
+			return result, nil
+		case []Decimal:
+			return v, nil
+		case Decimal:
+			return []Decimal{v}, nil
+		}
+	}
+	return nil, errors.New("no property decimal literal")
+}
+
+func (anEntity *Entity) GetFirstLangStringPropertyValue(typeURI string) (LangString, error) {
+	if values, found := anEntity.GetLangStringPropertyValues(typeURI); found == nil {
+		if len(values) == 0 {
+			return LangString{}, errors.New("no reference for type")
+		}
+		return values[0], nil
+	}
+	return LangString{}, errors.New("no reference for type")
+}
+
+func (anEntity *Entity) GetLangStringPropertyValues(typeURI string) ([]LangString, error) {
+	if values, found := anEntity.Properties[typeURI]; found {
+		switch v := values.(type) {
+		case []any:
+			result := make([]LangString, 0, len(v))
+			for _, val := range v {
+				if langVal, ok := val.(LangString); ok {
+					result = append(result, langVal)
+				}
+			}
+			return result, nil
+		case []LangString:
+			return v, nil
+		case LangString:
+			return []LangString{v}, nil
+		}
+	}
+	return nil, errors.New("no property language string literal")
+}
+
+func (anEntity *Entity) GetFirstBinaryPropertyValue(typeURI string) ([]byte, error) {
+	if values, found := anEntity.GetBinaryPropertyValues(typeURI); found == nil {
+		if len(values) == 0 {
+			return nil, errors.New("no reference for type")
+		}
+		return values[0], nil
+	}
+	return nil, errors.New("no reference for type")
+}
+
+func (anEntity *Entity) GetBinaryPropertyValues(typeURI string) ([][]byte, error) {
+	if values, found := anEntity.Properties[typeURI]; found {
+		switch v := values.(type) {
+		case []any:
+			result := make([][]byte, 0, len(v))
+			for _, val := range v {
+				if binaryVal, ok := val.(Binary); ok {
+					result = append(result, binaryVal)
+				}
+			}
+			return result, nil
+		case Binary:
+			return [][]byte{v}, nil
+		}
+	}
+	return nil, errors.New("no property binary literal")
+}
+
+// toTime converts the date and time values to time.Time
+func toTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case DateTime:
+		return v.Time, true
+	case Date:
+		return v.Time, true
+	case time.Time:
+		return v, true
+	}
+	return time.Time{}, false
+}
+
+// toInt converts the numeric representations produced by the parser to int. Values that are not integral, such as 1.5,
+// and values that do not fit in int are skipped whatever their representation, so the result does not depend on the
+// number mode of the parser.
+func toInt(value any) (int, bool) {
+	i, ok := toInt64(value)
+	if !ok || i < math.MinInt || i > math.MaxInt {
+		return 0, false
+	}
+	return int(i), true
+}
+
+// toInt64 converts the numeric representations produced by the parser to int64, it fails for values that are not
+// integral or that are out of range instead of truncating them
+func toInt64(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := v.Float64(); err == nil {
+			return toInt64(f)
+		}
+	case *big.Float:
+		if i, accuracy := v.Int64(); v.IsInt() && accuracy == big.Exact {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// toUint64 converts the numeric representations produced by the parser to uint64, it fails for values that are
+// negative, not integral or out of range instead of truncating them
+func toUint64(value any) (uint64, bool) {
+	switch v := value.(type) {
+	case uint64:
+		return v, true
+	case json.Number:
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u, true
+		}
+	case float64:
+		if v >= 0 && v == math.Trunc(v) && v < math.MaxUint64 {
+			return uint64(v), true
+		}
+		return 0, false
+	case *big.Float:
+		if u, accuracy := v.Uint64(); v.IsInt() && accuracy == big.Exact {
+			return u, true
+		}
+		return 0, false
+	}
+	if i, ok := toInt64(value); ok && i >= 0 {
+		return uint64(i), true
+	}
+	return 0, false
+}
+
+// toFloat converts the numeric representations produced by the parser to float64
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f, true
+		}
+	case *big.Float:
+		f, _ := v.Float64()
+		return f, true
+	}
+	return 0, false
+}
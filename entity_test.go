@@ -2,6 +2,8 @@ package egdm
 
 // basic go test for the parser using standard test setup
 import (
+	"encoding/json"
+	"math/big"
 	"testing"
 )
 
@@ -638,3 +640,327 @@ func TestCreateEntityUsingContextManager(t *testing.T) {
 		t.Errorf("expected entity reference to be 'ns0:entity2', got '%s'", entity.References["ns0:reference1"])
 	}
 }
+
+func TestMergeCollectionsWithCollidingPrefixes(t *testing.T) {
+	first := NewEntityCollection(nil)
+	first.NamespaceManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	first.NamespaceManager.StorePrefixExpansionMapping("_", "http://example.com/default/")
+	if err := first.AddEntity(NewEntity().SetID("ex:1").SetProperty("ex:name", "first")); err != nil {
+		t.Fatal(err)
+	}
+
+	second := NewEntityCollection(nil)
+	second.NamespaceManager.StorePrefixExpansionMapping("ex", "http://example.org/")
+	second.NamespaceManager.StorePrefixExpansionMapping("other", "http://example.com/")
+	second.NamespaceManager.StorePrefixExpansionMapping("_", "http://example.org/default/")
+	address := NewEntity().SetProperty("ex:street", "Main Street")
+	entity := NewEntity().SetID("ex:1").
+		SetProperty("ex:name", "second").
+		SetProperty("ex:address", address).
+		SetProperty("ex:previous", []any{map[string]any{"id": "ex:2", "props": map[string]any{"ex:street": "Old Street"}}}).
+		SetProperty("local", "default namespace").
+		SetReference("other:knows", []string{"ex:2", "http://example.net/3"}).
+		SetReference("ex:type", "local")
+	entity.Recorded = 42
+	if err := second.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Merge(second); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Entities) != 2 {
+		t.Fatalf("expected 2 entities, got %d", len(first.Entities))
+	}
+
+	merged := first.Entities[1]
+	// ex of the second collection is renamed, other is an alias of ex in the first collection and becomes ex
+	if merged.ID != "ex1:1" || merged.Recorded != 42 {
+		t.Errorf("unexpected id %s", merged.ID)
+	}
+	if merged.Properties["ex1:name"] != "second" || merged.Properties["ns1:local"] != "default namespace" {
+		t.Errorf("unexpected properties %v", merged.Properties)
+	}
+	if merged.Properties["ex1:address"].(*Entity).Properties["ex1:street"] != "Main Street" {
+		t.Errorf("unexpected embedded entity %v", merged.Properties["ex1:address"])
+	}
+	previous := merged.Properties["ex1:previous"].([]any)[0].(*Entity)
+	if previous.ID != "ex1:2" || previous.Properties["ex1:street"] != "Old Street" {
+		t.Errorf("unexpected embedded entity %v %v", previous.ID, previous.Properties)
+	}
+	knows, _ := merged.References["ex:knows"].([]string)
+	if len(knows) != 2 {
+		t.Fatalf("expected the alias to be rewritten to the prefix of the first collection, got %v", merged.References)
+	}
+	if knows[0] != "ex1:2" || knows[1] != "http://example.net/3" {
+		t.Errorf("unexpected references %v", merged.References)
+	}
+	if merged.References["ex1:type"] != "ns1:local" {
+		t.Errorf("unexpected references %v", merged.References)
+	}
+
+	if prefix, _ := first.NamespaceManager.GetPrefixForExpansion("http://example.com/"); prefix != "ex" {
+		t.Errorf("expected the first collection to keep ex for its expansion, got %s", prefix)
+	}
+	if first.NamespaceManager.DoesExpansionExistForPrefix("other") {
+		t.Errorf("expected the alias to not be stored in the first collection")
+	}
+
+	for _, id := range []string{merged.ID, "ex:knows", "ns1:local"} {
+		fullURI, err := first.NamespaceManager.GetFullURI(id)
+		if err != nil {
+			t.Errorf("unable to expand %s: %v", id, err)
+		}
+		if fullURI == "http://example.com/1" || fullURI == "http://example.com/default/local" {
+			t.Errorf("expected %s to keep the expansion of the second collection", id)
+		}
+	}
+
+	// the merged collection is not changed
+	if second.Entities[0].ID != "ex:1" || second.Entities[0].Properties["ex:address"] != address {
+		t.Errorf("expected the merged collection not to change")
+	}
+}
+
+func TestRebaseNamespaces(t *testing.T) {
+	ec := NewEntityCollection(nil)
+	ec.NamespaceManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	if err := ec.AddEntity(NewEntity().SetID("ex:1").SetReference("ex:knows", "ex:2")); err != nil {
+		t.Fatal(err)
+	}
+
+	target := NewNamespaceContext()
+	target.StorePrefixExpansionMapping("people", "http://example.com/")
+	if err := ec.RebaseNamespaces(target); err != nil {
+		t.Fatal(err)
+	}
+	if ec.NamespaceManager != target {
+		t.Errorf("expected the target to be the namespace manager of the collection")
+	}
+	// the expansion is already bound in the target, so its prefix is used and ex is not added
+	if ec.Entities[0].ID != "people:1" || ec.Entities[0].References["people:knows"] != "people:2" {
+		t.Errorf("unexpected entity %v %v", ec.Entities[0].ID, ec.Entities[0].References)
+	}
+	if prefix, _ := target.GetPrefixForExpansion("http://example.com/"); prefix != "people" || target.DoesExpansionExistForPrefix("ex") {
+		t.Errorf("expected the target to keep people for its expansion, got %s", prefix)
+	}
+
+	ec.NamespaceManager.StorePrefixExpansionMapping("other", "http://example.org/")
+	ec.Entities[0].SetReference("other:likes", "other:3")
+	if err := ec.RebaseNamespaces(NewNamespaceContext()); err != nil {
+		t.Fatal(err)
+	}
+	if fullURI, _ := ec.NamespaceManager.GetFullURI("other:3"); fullURI != "http://example.org/3" {
+		t.Errorf("expected other to be added to the target, got %s", fullURI)
+	}
+
+	ec.Entities[0].SetReference("missing:type", "ex:Person")
+	if err := ec.RebaseNamespaces(NewNamespaceContext()); err == nil {
+		t.Errorf("expected error for prefix without expansion")
+	}
+}
+
+func TestCompressPrefixes(t *testing.T) {
+	ec := NewEntityCollection(nil)
+	ec.NamespaceManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	entity := NewEntity().SetID("http://example.com/1")
+	entity.SetProperty("http://example.com/name", "John")
+	entity.SetProperty("http://example.com/address", NewEntity().SetProperty("http://example.com/address/street", "Main Street"))
+	entity.SetProperty("http://example.com/previous", []*Entity{NewEntity().SetID("http://example.com/address/2")})
+	entity.SetProperty("http://example.com/other", []any{map[string]any{
+		"id":    "http://other.example.com/3",
+		"props": map[string]any{"http://example.com/name": "Other"},
+		"refs":  map[string]any{"http://example.com/type": "ex:Address"},
+	}})
+	entity.SetProperty("http://example.com/tags", []any{"http://example.com/not-an-identifier"})
+	entity.SetReference("http://example.com/knows", []any{"http://example.com/2", "urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66"})
+	entity.SetReference("ex:type", "http://example.com/types/Person")
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ec.CompressNamespacePrefixes(); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := ec.Entities[0]
+	if compressed != entity {
+		t.Errorf("expected the entity to be changed in place")
+	}
+	if compressed.ID != "ex:1" || compressed.Properties["ex:name"] != "John" {
+		t.Errorf("unexpected entity %s %v", compressed.ID, compressed.Properties)
+	}
+	// generated prefixes are minted for expansions without a prefix, references are visited before properties
+	address := compressed.Properties["ex:address"].(*Entity)
+	if address.Properties["ns2:street"] != "Main Street" {
+		t.Errorf("unexpected address %v", address.Properties)
+	}
+	if compressed.Properties["ex:previous"].([]*Entity)[0].ID != "ns2:2" {
+		t.Errorf("unexpected previous %v", compressed.Properties["ex:previous"])
+	}
+	other := compressed.Properties["ex:other"].([]any)[0].(*Entity)
+	if other.ID != "ns3:3" || other.Properties["ex:name"] != "Other" || other.References["ex:type"] != "ex:Address" {
+		t.Errorf("unexpected other %v %v %v", other.ID, other.Properties, other.References)
+	}
+	if compressed.Properties["ex:tags"].([]any)[0] != "http://example.com/not-an-identifier" {
+		t.Errorf("expected property values not to be compressed")
+	}
+	knows := compressed.References["ex:knows"].([]any)
+	if knows[0] != "ex:2" || knows[1] != "urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66" {
+		t.Errorf("unexpected references %v", knows)
+	}
+	if compressed.References["ex:type"] != "ns1:Person" {
+		t.Errorf("unexpected type %v", compressed.References["ex:type"])
+	}
+
+	// expanding gives back the full URIs
+	if err := ec.ExpandNamespacePrefixes(); err != nil {
+		t.Fatal(err)
+	}
+	if ec.Entities[0].ID != "http://example.com/1" || ec.Entities[0].References["http://example.com/type"] != "http://example.com/types/Person" {
+		t.Errorf("unexpected expanded entity %v %v", ec.Entities[0].ID, ec.Entities[0].References)
+	}
+}
+
+func TestEntityCollectionIndex(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	ec := NewEntityCollection(nsManager)
+
+	first := NewEntity().SetID("ex:1")
+	second := NewEntity().SetID("http://example.com/2")
+	if err := ec.AddEntity(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.AddEntity(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.AddEntityFromMap(map[string]any{"id": "ex:3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the CURIE and full URI forms of an id are the same
+	if ec.GetEntity("http://example.com/1") != first || ec.GetEntity("ex:2") != second {
+		t.Errorf("expected entities to be found by either form of the id")
+	}
+	if !ec.HasEntity("ex:3") || ec.HasEntity("ex:4") {
+		t.Errorf("unexpected HasEntity result")
+	}
+
+	// the last entity added with an id is returned
+	newer := NewEntity().SetID("http://example.com/1")
+	if err := ec.AddEntity(newer); err != nil {
+		t.Fatal(err)
+	}
+	if ec.GetEntity("ex:1") != newer {
+		t.Errorf("expected the newest entity")
+	}
+
+	if !ec.RemoveEntity("ex:1") {
+		t.Errorf("expected entity to be removed")
+	}
+	if ec.RemoveEntity("ex:1") || ec.HasEntity("ex:1") || len(ec.Entities) != 2 {
+		t.Errorf("expected all entities with the id to be removed, got %d entities", len(ec.Entities))
+	}
+	if ec.GetEntity("ex:3").ID != "ex:3" {
+		t.Errorf("expected positions to be updated after remove")
+	}
+
+	// changes to the slice are picked up
+	ec.Entities = []*Entity{first}
+	if ec.GetEntity("ex:1") != first || ec.HasEntity("ex:2") {
+		t.Errorf("expected index to be rebuilt")
+	}
+	if err := ec.CompressNamespacePrefixes(); err != nil {
+		t.Fatal(err)
+	}
+	if ec.GetEntity("ex:1") == nil {
+		t.Errorf("expected compressed entity to be found")
+	}
+
+	// a collection that is not created with NewEntityCollection builds its index on the first lookup
+	literal := &EntityCollection{NamespaceManager: nsManager, Entities: []*Entity{second}}
+	if literal.GetEntity("ex:2") != second {
+		t.Errorf("expected entity in collection literal to be found")
+	}
+}
+
+func TestEntityCollectionIndexFollowsNamespaceMappings(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	ec := NewEntityCollection(nsManager)
+	ec.SetDuplicatePolicy(DuplicateError)
+
+	entity := NewEntity().SetID("ex:1")
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	// the prefix is bound after the entity was indexed
+	if _, err := ec.StorePrefixExpansionMapping("ex", "http://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	if ec.GetEntity("http://example.com/1") != entity {
+		t.Errorf("expected entity to be found by full URI after the prefix was bound")
+	}
+	if err := ec.AddEntity(NewEntity().SetID("http://example.com/1")); err == nil {
+		t.Errorf("expected duplicate to be detected after the prefix was bound")
+	}
+
+	// the prefix is rebound directly in the namespace manager
+	nsManager.StorePrefixExpansionMapping("ex", "http://other.example.com/")
+	if ec.HasEntity("http://example.com/1") || ec.GetEntity("http://other.example.com/1") != entity {
+		t.Errorf("expected index to follow the rebound prefix")
+	}
+
+	// the namespace manager is replaced
+	replacement := NewNamespaceContext()
+	replacement.StorePrefixExpansionMapping("ex", "http://replaced.example.com/")
+	ec.NamespaceManager = replacement
+	if ec.GetEntity("http://replaced.example.com/1") != entity {
+		t.Errorf("expected index to follow the replaced namespace manager")
+	}
+
+	if err := ec.RebaseNamespaces(NewNamespaceContext()); err != nil {
+		t.Fatal(err)
+	}
+	if ec.GetEntity("http://replaced.example.com/1") == nil {
+		t.Errorf("expected entity to be found after rebasing")
+	}
+}
+
+func TestGetIntPropertyValuesSkipsValuesOutsideInt(t *testing.T) {
+	entity := NewEntity().SetID("ex:1")
+	entity.Properties["ex:values"] = []any{
+		uint64(18446744073709551615),
+		json.Number("1e30"),
+		json.Number("1.5"),
+		new(big.Float).SetFloat64(2.5),
+		json.Number("9007199254740993"),
+		uint64(7),
+		new(big.Float).SetInt64(-3),
+		42.9,
+	}
+
+	values, err := entity.GetIntPropertyValues("ex:values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 3 || values[0] != 9007199254740993 || values[1] != 7 || values[2] != -3 {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	entity.Properties["ex:floats"] = []float64{1, 42.9, -2}
+	values, err = entity.GetIntPropertyValues("ex:floats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[0] != 1 || values[1] != -2 {
+		t.Errorf("expected non-integral float to be skipped, got %v", values)
+	}
+
+	entity.Properties["ex:value"] = uint64(18446744073709551615)
+	if _, err := entity.GetFirstIntPropertyValue("ex:value"); err == nil {
+		t.Errorf("expected value outside int to be skipped")
+	}
+}
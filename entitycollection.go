@@ -1,9 +1,12 @@
 package egdm
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
+	"maps"
+	"strings"
 )
 
 // EntityCollection is a utility structure for collecting together a set of entities, namespace mappings and a continuation token
@@ -12,6 +15,17 @@ type EntityCollection struct {
 	Continuation       *Continuation
 	NamespaceManager   NamespaceManager
 	OmitContextOnWrite bool
+	// PrefixConflictPolicy is used when namespace mappings are stored through the collection
+	PrefixConflictPolicy PrefixConflictPolicy
+	// DuplicatePolicy is used when an entity is added with the id of an entity in the collection
+	DuplicatePolicy DuplicatePolicy
+
+	// index holds the positions in indexedEntities of the entities with each full URI id
+	index           map[string][]int
+	indexedEntities []*Entity
+	// indexedNamespaceManager and indexedMappings are the namespace manager and mappings the index keys were expanded with
+	indexedNamespaceManager NamespaceManager
+	indexedMappings         map[string]string
 }
 
 func NewEntityCollection(nsManager NamespaceManager) *EntityCollection {
@@ -22,6 +36,7 @@ func NewEntityCollection(nsManager NamespaceManager) *EntityCollection {
 	}
 	ec.NamespaceManager = nsManager
 	ec.Entities = make([]*Entity, 0)
+	ec.rebuildIndex()
 	return ec
 }
 
@@ -31,17 +46,158 @@ func (ec *EntityCollection) SetOmitContextOnWrite(isOmitted bool) {
 	ec.OmitContextOnWrite = isOmitted
 }
 
+// SetPrefixConflictPolicy sets the policy used when a namespace mapping stored through the collection binds a prefix
+// that is already bound to a different expansion
+func (ec *EntityCollection) SetPrefixConflictPolicy(policy PrefixConflictPolicy) {
+	ec.PrefixConflictPolicy = policy
+}
+
+// StorePrefixExpansionMapping stores the mapping in the namespace manager of the collection using the prefix conflict
+// policy of the collection. The returned conflict is nil if the prefix was unbound or bound to the same expansion.
+func (ec *EntityCollection) StorePrefixExpansionMapping(prefix string, expansion string) (*PrefixConflict, error) {
+	return storePrefixExpansionMappingWithPolicy(ec.NamespaceManager, prefix, expansion, ec.PrefixConflictPolicy)
+}
+
+// SetDuplicatePolicy sets the policy used when an entity is added with the id of an entity that is already in the
+// collection
+func (ec *EntityCollection) SetDuplicatePolicy(policy DuplicatePolicy) {
+	ec.DuplicatePolicy = policy
+}
+
 // SetContinuationToken sets the continuation token on the EntityCollection
 func (ec *EntityCollection) SetContinuationToken(continuation *Continuation) {
 	ec.Continuation = continuation
 }
 
-// AddEntity adds the given entity to the collection
+// AddEntity adds the given entity to the collection. If the collection already has an entity with the same id the
+// DuplicatePolicy of the collection decides which entity is kept.
 func (ec *EntityCollection) AddEntity(entity *Entity) error {
+	if ec.DuplicatePolicy != DuplicateAppend && entity.ID != "" {
+		ec.ensureIndex()
+		positions := ec.index[ec.indexKey(entity.ID)]
+		if len(positions) > 0 {
+			position := positions[len(positions)-1]
+			kept, err := resolveDuplicate(ec.Entities[position], entity, ec.DuplicatePolicy)
+			if err != nil {
+				return err
+			}
+			ec.Entities[position] = kept
+			return nil
+		}
+	}
+
+	isIndexed := ec.isIndexCurrent()
 	ec.Entities = append(ec.Entities, entity)
+	if isIndexed {
+		ec.indexEntity(entity, len(ec.Entities)-1)
+		ec.indexedEntities = ec.Entities
+	}
 	return nil
 }
 
+// GetEntity returns the entity with the id, or nil if the collection has no such entity. The id can be given as a
+// CURIE or as a full URI. If several entities have the id the one added last is returned. The lookup uses an index
+// that is kept up to date by AddEntity and rebuilt when the Entities slice is replaced or its length changes, or when the
+// namespace mappings change; changing the id of an entity in the collection or an element of the slice in place is not
+// noticed.
+func (ec *EntityCollection) GetEntity(id string) *Entity {
+	ec.ensureIndex()
+	positions := ec.index[ec.indexKey(id)]
+	if len(positions) == 0 {
+		return nil
+	}
+	return ec.Entities[positions[len(positions)-1]]
+}
+
+// HasEntity returns true if the collection has an entity with the id, given as a CURIE or as a full URI
+func (ec *EntityCollection) HasEntity(id string) bool {
+	ec.ensureIndex()
+	return len(ec.index[ec.indexKey(id)]) > 0
+}
+
+// RemoveEntity removes all entities with the id, given as a CURIE or as a full URI, from the collection. It returns
+// true if an entity was removed.
+func (ec *EntityCollection) RemoveEntity(id string) bool {
+	ec.ensureIndex()
+	key := ec.indexKey(id)
+	positions := ec.index[key]
+	if len(positions) == 0 {
+		return false
+	}
+
+	removed := make(map[int]bool, len(positions))
+	for _, position := range positions {
+		removed[position] = true
+	}
+	entities := make([]*Entity, 0, len(ec.Entities)-len(positions))
+	for i, entity := range ec.Entities {
+		if !removed[i] {
+			entities = append(entities, entity)
+		}
+	}
+	ec.Entities = entities
+	ec.rebuildIndex()
+	return true
+}
+
+// isIndexCurrent returns true if the index was built from the current Entities slice with the current namespace
+// mappings. Entities that are changed directly, for example by assigning or appending to the slice, and prefixes that
+// are bound or rebound, also directly in the namespace manager, cause the index to be rebuilt on the next lookup.
+func (ec *EntityCollection) isIndexCurrent() bool {
+	if ec.index == nil || len(ec.indexedEntities) != len(ec.Entities) {
+		return false
+	}
+	if len(ec.Entities) > 0 && &ec.indexedEntities[0] != &ec.Entities[0] {
+		return false
+	}
+	return ec.indexedNamespaceManager == ec.NamespaceManager && maps.Equal(ec.indexedMappings, ec.namespaceMappings())
+}
+
+// namespaceMappings returns the mappings of the namespace manager, or nil if the collection has none
+func (ec *EntityCollection) namespaceMappings() map[string]string {
+	if ec.NamespaceManager == nil {
+		return nil
+	}
+	return ec.NamespaceManager.GetNamespaceMappings()
+}
+
+func (ec *EntityCollection) ensureIndex() {
+	if !ec.isIndexCurrent() {
+		ec.rebuildIndex()
+	}
+}
+
+func (ec *EntityCollection) rebuildIndex() {
+	ec.index = make(map[string][]int, len(ec.Entities))
+	for i, entity := range ec.Entities {
+		ec.indexEntity(entity, i)
+	}
+	ec.indexedEntities = ec.Entities
+	ec.indexedNamespaceManager = ec.NamespaceManager
+	ec.indexedMappings = ec.namespaceMappings()
+}
+
+func (ec *EntityCollection) indexEntity(entity *Entity, position int) {
+	if entity.ID == "" {
+		return
+	}
+	key := ec.indexKey(entity.ID)
+	ec.index[key] = append(ec.index[key], position)
+}
+
+// indexKey returns the full URI of the id so that the CURIE and full URI forms of an id find the same entity. Ids
+// that can not be expanded are used as they are.
+func (ec *EntityCollection) indexKey(id string) string {
+	if ec.NamespaceManager == nil {
+		return id
+	}
+	fullURI, err := ec.NamespaceManager.GetFullURI(id)
+	if err != nil {
+		return id
+	}
+	return fullURI
+}
+
 // AddEntityFromMap adds an entity to the collection from a map
 // The map should have the following structure (the keys are case sensitive):
 //
@@ -56,6 +212,19 @@ func (ec *EntityCollection) AddEntity(entity *Entity) error {
 //	    "ns0:reference1": "ns0:entity2"
 //	  }
 func (ec *EntityCollection) AddEntityFromMap(data map[string]any) error {
+	entity := newEntityFromMap(data)
+
+	// add entity to collection
+	err := ec.AddEntity(entity)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// newEntityFromMap creates an entity from a map with the structure described on AddEntityFromMap
+func newEntityFromMap(data map[string]any) *Entity {
 	entity := NewEntity()
 
 	// get metadata
@@ -78,6 +247,10 @@ func (ec *EntityCollection) AddEntityFromMap(data map[string]any) error {
 		if _, ok := recorded.(uint64); ok {
 			entity.Recorded = recorded.(uint64)
 		}
+
+		if number, ok := recorded.(json.Number); ok {
+			entity.Recorded, _ = parseUint(number)
+		}
 	}
 
 	// get props
@@ -94,13 +267,7 @@ func (ec *EntityCollection) AddEntityFromMap(data map[string]any) error {
 		}
 	}
 
-	// add entity to collection
-	err := ec.AddEntity(entity)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return entity
 }
 
 func (ec *EntityCollection) GetEntities() []*Entity {
@@ -131,80 +298,171 @@ func NewContext() *Context {
 	return c
 }
 
+// WriteEntityGraphJSON writes the collection as Entity Graph JSON
 func (ec *EntityCollection) WriteEntityGraphJSON(writer io.Writer) error {
-	var err error
+	return ec.WriteEntityGraphJSONContext(context.Background(), writer)
+}
 
-	// write [
-	_, err = writer.Write([]byte("[\n"))
+// WriteEntityGraphJSONContext is WriteEntityGraphJSON but checks the context between entities and returns ctx.Err()
+// when it is done. The output is incomplete when the context is done.
+func (ec *EntityCollection) WriteEntityGraphJSONContext(ctx context.Context, writer io.Writer) error {
+	return ec.writeEntityGraph(ctx, NewEntityGraphWriter(writer, ec.NamespaceManager))
+}
+
+// WriteEntityGraphNDJSON writes the collection as line delimited Entity Graph JSON
+func (ec *EntityCollection) WriteEntityGraphNDJSON(writer io.Writer) error {
+	return ec.WriteEntityGraphNDJSONContext(context.Background(), writer)
+}
+
+// WriteEntityGraphNDJSONContext is WriteEntityGraphNDJSON but checks the context between entities and returns
+// ctx.Err() when it is done
+func (ec *EntityCollection) WriteEntityGraphNDJSONContext(ctx context.Context, writer io.Writer) error {
+	return ec.writeEntityGraph(ctx, NewEntityGraphWriter(writer, ec.NamespaceManager).WithNDJSON())
+}
+
+func (ec *EntityCollection) writeEntityGraph(ctx context.Context, entityGraphWriter *EntityGraphWriter) error {
+	if ec.OmitContextOnWrite {
+		entityGraphWriter.WithOmitContext()
+	}
+
+	for _, entity := range ec.Entities {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := entityGraphWriter.WriteEntity(entity)
+		if err != nil {
+			return err
+		}
+	}
+
+	return entityGraphWriter.Close(ec.Continuation)
+}
+
+func (ec *EntityCollection) WriteJSON_LD(writer io.Writer) error {
+	return NewJsonLDWriter().Write(ec, writer)
+}
+
+// WriteNTriples writes the collection as N-Triples
+func (ec *EntityCollection) WriteNTriples(writer io.Writer) error {
+	return NewNTriplesWriter().Write(ec, writer)
+}
+
+// WriteNQuads writes the collection as N-Quads with all statements in the given graph
+func (ec *EntityCollection) WriteNQuads(writer io.Writer, graph string) error {
+	return NewNTriplesWriter().WithGraph(graph).Write(ec, writer)
+}
+
+// WriteTurtle writes the collection as Turtle using the namespace mappings as prefix declarations
+func (ec *EntityCollection) WriteTurtle(writer io.Writer) error {
+	return NewTurtleWriter().Write(ec, writer)
+}
+
+// RebaseNamespaces rewrites every CURIE in the collection, including those of embedded entities, to use the prefixes
+// of the target namespace manager and makes the target the namespace manager of the collection. The expansions used by
+// the collection use the prefix the target binds them to, expansions the target has no prefix for are added to it and a
+// prefix that is bound to a different expansion in the target is renamed. Full URIs are not changed.
+func (ec *EntityCollection) RebaseNamespaces(target NamespaceManager) error {
+	entities, err := ec.rebaseEntities(target)
 	if err != nil {
 		return err
 	}
+	ec.Entities = entities
+	ec.NamespaceManager = target
+	return nil
+}
 
-	// write context
-	if !ec.OmitContextOnWrite {
-		context := NewContext()
-		context.ID = "@context"
-		context.Namespaces = ec.NamespaceManager.GetNamespaceMappings()
-		contextJson, _ := json.Marshal(context)
-		_, err = writer.Write(contextJson)
+// Merge adds the entities of the other collection to this collection. The CURIEs of the other collection are rewritten
+// to the namespace manager of this collection as described on RebaseNamespaces. The other collection is not changed.
+func (ec *EntityCollection) Merge(other *EntityCollection) error {
+	entities, err := other.rebaseEntities(ec.NamespaceManager)
+	if err != nil {
+		return err
+	}
+	for _, entity := range entities {
+		err = ec.AddEntity(entity)
 		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	writtenFirstEntity := false
-
-	// write entities
-	for _, entity := range ec.Entities {
+// rebaseEntities returns copies of the entities with the CURIEs rewritten to the prefixes of the target
+func (ec *EntityCollection) rebaseEntities(target NamespaceManager) ([]*Entity, error) {
+	// the prefix used in the target for each prefix of the collection
+	prefixes := make(map[string]string)
+	rebase := func(identifier string) (string, error) {
+		if ec.NamespaceManager.IsFullUri(identifier) {
+			return identifier, nil
+		}
+		prefix, local, isCURIE := strings.Cut(identifier, ":")
+		if !isCURIE {
+			prefix, local = "_", identifier
+		}
 
-		if writtenFirstEntity || !ec.OmitContextOnWrite {
-			_, err = writer.Write([]byte(",\n"))
+		targetPrefix, found := prefixes[prefix]
+		if !found {
+			expansion, err := ec.NamespaceManager.GetNamespaceExpansionForPrefix(prefix)
 			if err != nil {
-				return err
+				return "", err
+			}
+			// an expansion the target already binds keeps the prefix of the target, also when the collection uses an alias
+			targetPrefix, found = boundPrefixForExpansion(target, expansion)
+			if !found {
+				conflict, err := storePrefixExpansionMappingWithPolicy(target, prefix, expansion, PrefixConflictRename)
+				if err != nil {
+					return "", err
+				}
+				targetPrefix = prefix
+				if conflict != nil {
+					targetPrefix = conflict.ResolvedPrefix
+				}
 			}
+			prefixes[prefix] = targetPrefix
 		}
-		writtenFirstEntity = true
 
-		entityJson, err := json.Marshal(entity)
-		if err != nil {
-			return err
-		}
-		_, err = writer.Write(entityJson)
-		if err != nil {
-			return err
+		if targetPrefix == "_" {
+			return local, nil
 		}
+		return targetPrefix + ":" + local, nil
 	}
 
-	// write continuation if not nil
-	if ec.Continuation != nil {
-		contJson, err := json.Marshal(ec.Continuation)
+	entities := make([]*Entity, 0, len(ec.Entities))
+	for _, entity := range ec.Entities {
+		rebased, err := rewriteEntity(entity, rebase)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		_, err = writer.Write([]byte(", "))
-		if err != nil {
-			return err
+		entities = append(entities, rebased)
+	}
+	return entities, nil
+}
+
+// CompressNamespacePrefixes is the inverse of ExpandNamespacePrefixes. It rewrites the full URIs in ids, property and
+// reference keys, reference values and embedded entities to prefixed identifiers, storing generated prefixes in the
+// namespace manager for expansions that have none. Identifiers that are already prefixed are not changed. Like
+// ExpandNamespacePrefixes it changes the entities of the collection in place, their embedded entities are replaced by
+// compressed copies.
+func (ec *EntityCollection) CompressNamespacePrefixes() error {
+	compress := func(identifier string) (string, error) {
+		if !ec.NamespaceManager.IsFullUri(identifier) {
+			return identifier, nil
 		}
-		_, err = writer.Write([]byte(contJson))
+		return compressIdentifier(ec.NamespaceManager, identifier)
+	}
+
+	for _, entity := range ec.Entities {
+		compressed, err := rewriteEntity(entity, compress)
 		if err != nil {
 			return err
 		}
+		entity.ID = compressed.ID
+		entity.Properties = compressed.Properties
+		entity.References = compressed.References
 	}
-
-	// write ]
-	_, err = writer.Write([]byte("\n]"))
-	if err != nil {
-		return err
-	}
-
 	return nil
 }
 
-func (ec *EntityCollection) WriteJSON_LD(writer io.Writer) error {
-	jsonLDWriter := &JsonLDWriter{}
-	return jsonLDWriter.Write(ec, writer)
-}
-
 func (ec *EntityCollection) ExpandNamespacePrefixes() error {
 	var err error
 	for _, entity := range ec.Entities {
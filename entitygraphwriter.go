@@ -0,0 +1,152 @@
+package egdm
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// EntityGraphWriter writes Entity Graph JSON incrementally. The context is written ahead of the first entity,
+// entities are written one at a time with WriteEntity and Close writes the optional continuation and the closing bracket.
+// The output is identical to that of EntityCollection.WriteEntityGraphJSON.
+type EntityGraphWriter struct {
+	writer             io.Writer
+	nsManager          NamespaceManager
+	omitContext        bool
+	lineDelimited      bool
+	started            bool
+	closed             bool
+	writtenFirstEntity bool
+}
+
+func NewEntityGraphWriter(writer io.Writer, nsManager NamespaceManager) *EntityGraphWriter {
+	egw := &EntityGraphWriter{}
+	egw.writer = writer
+	// default to inbuilt namespace manager if not defined
+	if nsManager == nil {
+		nsManager = NewNamespaceContext()
+	}
+	egw.nsManager = nsManager
+	return egw
+}
+
+// WithOmitContext configures the writer to not write the @context object
+func (egw *EntityGraphWriter) WithOmitContext() *EntityGraphWriter {
+	egw.omitContext = true
+	return egw
+}
+
+// WithNDJSON configures the writer to write the line delimited variant of Entity Graph JSON. Each object is written
+// on its own line and there are no enclosing brackets.
+func (egw *EntityGraphWriter) WithNDJSON() *EntityGraphWriter {
+	egw.lineDelimited = true
+	return egw
+}
+
+// WriteEntity writes the given entity. The opening bracket and context are written before the first entity.
+func (egw *EntityGraphWriter) WriteEntity(entity *Entity) error {
+	if egw.closed {
+		return errors.New("unable to write entity: writer is closed")
+	}
+
+	err := egw.writeStart()
+	if err != nil {
+		return err
+	}
+
+	if (egw.writtenFirstEntity || !egw.omitContext) && !egw.lineDelimited {
+		_, err = egw.writer.Write([]byte(",\n"))
+		if err != nil {
+			return err
+		}
+	}
+	egw.writtenFirstEntity = true
+
+	entityJson, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	return egw.writeRecord(entityJson)
+}
+
+// Close writes the continuation, if not nil, and the closing bracket. The underlying writer is not closed.
+func (egw *EntityGraphWriter) Close(continuation *Continuation) error {
+	if egw.closed {
+		return errors.New("writer is already closed")
+	}
+
+	err := egw.writeStart()
+	if err != nil {
+		return err
+	}
+	egw.closed = true
+
+	// write continuation if not nil
+	if continuation != nil {
+		contJson, err := json.Marshal(continuation)
+		if err != nil {
+			return err
+		}
+		if (egw.writtenFirstEntity || !egw.omitContext) && !egw.lineDelimited {
+			_, err = egw.writer.Write([]byte(", "))
+			if err != nil {
+				return err
+			}
+		}
+		err = egw.writeRecord(contJson)
+		if err != nil {
+			return err
+		}
+	}
+
+	if egw.lineDelimited {
+		return nil
+	}
+
+	// write ]
+	_, err = egw.writer.Write([]byte("\n]"))
+	return err
+}
+
+func (egw *EntityGraphWriter) writeStart() error {
+	if egw.started {
+		return nil
+	}
+	egw.started = true
+
+	// write [
+	if !egw.lineDelimited {
+		_, err := egw.writer.Write([]byte("[\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	// write context
+	if !egw.omitContext {
+		context := NewContext()
+		context.Namespaces = egw.nsManager.GetNamespaceMappings()
+		contextJson, err := json.Marshal(context)
+		if err != nil {
+			return err
+		}
+		err = egw.writeRecord(contextJson)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeRecord writes a single encoded object, terminated by a newline when writing line delimited output
+func (egw *EntityGraphWriter) writeRecord(data []byte) error {
+	_, err := egw.writer.Write(data)
+	if err != nil {
+		return err
+	}
+	if egw.lineDelimited {
+		_, err = egw.writer.Write([]byte("\n"))
+	}
+	return err
+}
@@ -0,0 +1,146 @@
+package egdm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestEntityGraphWriterMatchesCollectionOutput(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	ec := NewEntityCollection(nsManager)
+	for _, id := range []string{"ex:1", "ex:2", "ex:3"} {
+		entity := NewEntity().SetID(id)
+		entity.SetProperty("ex:name", "name of "+id)
+		entity.SetReference("ex:type", "ex:Person")
+		if err := ec.AddEntity(entity); err != nil {
+			t.Fatal(err)
+		}
+	}
+	continuation := NewContinuation()
+	continuation.Token = "1234"
+	ec.SetContinuationToken(continuation)
+
+	collectionBuffer := bytes.Buffer{}
+	if err := ec.WriteEntityGraphJSON(&collectionBuffer); err != nil {
+		t.Fatal(err)
+	}
+
+	writerBuffer := bytes.Buffer{}
+	writer := NewEntityGraphWriter(&writerBuffer, nsManager)
+	for _, entity := range ec.Entities {
+		if err := writer.WriteEntity(entity); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(continuation); err != nil {
+		t.Fatal(err)
+	}
+
+	if collectionBuffer.String() != writerBuffer.String() {
+		t.Errorf("expected streamed output to match collection output\n%s\n%s", collectionBuffer.String(), writerBuffer.String())
+	}
+
+	expected := `[
+{"id":"@context","namespaces":{"ex":"http://example.com/"}},
+{"id":"ex:1","refs":{"ex:type":"ex:Person"},"props":{"ex:name":"name of ex:1"}},
+{"id":"ex:2","refs":{"ex:type":"ex:Person"},"props":{"ex:name":"name of ex:2"}},
+{"id":"ex:3","refs":{"ex:type":"ex:Person"},"props":{"ex:name":"name of ex:3"}}, {"id":"@continuation","token":"1234"}
+]`
+	if writerBuffer.String() != expected {
+		t.Errorf("unexpected output:\n%s", writerBuffer.String())
+	}
+}
+
+func TestEntityGraphWriterOutputCanBeParsed(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	buffer := bytes.Buffer{}
+	writer := NewEntityGraphWriter(&buffer, nsManager)
+	for i := 0; i < 100; i++ {
+		if err := writer.WriteEntity(NewEntity().SetID("ex:1").SetProperty("ex:count", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	continuation := NewContinuation()
+	continuation.Token = "next"
+	if err := writer.Close(continuation); err != nil {
+		t.Fatal(err)
+	}
+
+	ec, err := NewEntityParser(NewNamespaceContext()).LoadEntityCollection(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ec.Entities) != 100 {
+		t.Errorf("expected 100 entities, got %d", len(ec.Entities))
+	}
+	if ec.Continuation == nil || ec.Continuation.Token != "next" {
+		t.Errorf("expected continuation token next, got %v", ec.Continuation)
+	}
+}
+
+func TestEntityGraphWriterWithOmittedContextAndNoEntities(t *testing.T) {
+	buffer := bytes.Buffer{}
+	writer := NewEntityGraphWriter(&buffer, nil).WithOmitContext()
+	continuation := NewContinuation()
+	continuation.Token = "next"
+	if err := writer.Close(continuation); err != nil {
+		t.Fatal(err)
+	}
+
+	ec, err := NewEntityParser(NewNamespaceContext()).WithNoContext().LoadEntityCollection(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatalf("expected output to be valid, got %s: %s", err, buffer.String())
+	}
+	if ec.Continuation == nil || ec.Continuation.Token != "next" {
+		t.Errorf("expected continuation token next, got %v", ec.Continuation)
+	}
+}
+
+func TestEntityGraphWriterRejectsWritesAfterClose(t *testing.T) {
+	buffer := bytes.Buffer{}
+	writer := NewEntityGraphWriter(&buffer, nil)
+	if err := writer.Close(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteEntity(NewEntity().SetID("http://example.com/1")); err == nil {
+		t.Error("expected error when writing entity after close")
+	}
+	if err := writer.Close(nil); err == nil {
+		t.Error("expected error when closing writer twice")
+	}
+	if buffer.String() != "[\n{\"id\":\"@context\",\"namespaces\":{}}\n]" {
+		t.Errorf("unexpected output: %s", buffer.String())
+	}
+}
+
+func TestWriteEntityGraphJSONContextCancellation(t *testing.T) {
+	ec := NewEntityCollection(nil)
+	_ = ec.AddEntity(NewEntity().SetID("http://example.com/1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	buffer := bytes.Buffer{}
+	if err := ec.WriteEntityGraphJSONContext(ctx, &buffer); err != context.Canceled {
+		t.Errorf("expected context error, got %v", err)
+	}
+	if err := ec.WriteEntityGraphNDJSONContext(ctx, &buffer); err != context.Canceled {
+		t.Errorf("expected context error, got %v", err)
+	}
+	if buffer.Len() != 0 {
+		t.Errorf("expected nothing to be written, got %s", buffer.String())
+	}
+
+	if err := ec.WriteEntityGraphJSONContext(context.Background(), &buffer); err != nil {
+		t.Fatal(err)
+	}
+	expected := bytes.Buffer{}
+	_ = ec.WriteEntityGraphJSON(&expected)
+	if buffer.String() != expected.String() {
+		t.Errorf("expected the same output as WriteEntityGraphJSON, got %s", buffer.String())
+	}
+}
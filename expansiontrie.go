@@ -0,0 +1,95 @@
+package egdm
+
+import "strings"
+
+// expansionTrie maps namespace expansions to prefixes and finds the longest expansion that a URI starts with.
+// It is a radix tree, each edge holds the longest common part of the expansions below it, so the lookup time
+// depends on the length of the URI and not on the number of expansions.
+type expansionTrie struct {
+	root *expansionTrieNode
+}
+
+type expansionTrieNode struct {
+	label    string
+	children map[byte]*expansionTrieNode
+	prefix   string
+	isEnd    bool
+}
+
+func newExpansionTrie() *expansionTrie {
+	return &expansionTrie{root: &expansionTrieNode{}}
+}
+
+func (trie *expansionTrie) insert(expansion string, prefix string) {
+	node := trie.root
+	rest := expansion
+	for rest != "" {
+		child, found := node.children[rest[0]]
+		if !found {
+			if node.children == nil {
+				node.children = make(map[byte]*expansionTrieNode)
+			}
+			node.children[rest[0]] = &expansionTrieNode{label: rest, prefix: prefix, isEnd: true}
+			return
+		}
+
+		common := commonPrefixLength(rest, child.label)
+		if common < len(child.label) {
+			// split the edge at the end of the common part
+			split := &expansionTrieNode{
+				label:    child.label[:common],
+				children: map[byte]*expansionTrieNode{child.label[common]: child},
+			}
+			child.label = child.label[common:]
+			node.children[rest[0]] = split
+			child = split
+		}
+		node = child
+		rest = rest[common:]
+	}
+	node.prefix = prefix
+	node.isEnd = true
+}
+
+// longestMatch returns the longest expansion that the value starts with and that is accepted, together with its prefix
+func (trie *expansionTrie) longestMatch(value string, accept func(expansion string, prefix string) bool) (string, string, bool) {
+	// the matches are collected while walking down and tried from the deepest node
+	var matchBuffer [8]int
+	var nodeBuffer [8]*expansionTrieNode
+	matches := matchBuffer[:0]
+	nodes := nodeBuffer[:0]
+
+	node := trie.root
+	position := 0
+	for {
+		if node.isEnd {
+			matches = append(matches, position)
+			nodes = append(nodes, node)
+		}
+		if position == len(value) {
+			break
+		}
+		child, found := node.children[value[position]]
+		if !found || !strings.HasPrefix(value[position:], child.label) {
+			break
+		}
+		node = child
+		position += len(child.label)
+	}
+
+	for i := len(matches) - 1; i >= 0; i-- {
+		expansion := value[:matches[i]]
+		if accept(expansion, nodes[i].prefix) {
+			return expansion, nodes[i].prefix, true
+		}
+	}
+	return "", "", false
+}
+
+func commonPrefixLength(a string, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,59 @@
+package egdm
+
+import (
+	"strings"
+)
+
+// defaultIRISchemes are the schemes of values that every NamespaceContext recognises as IRIs rather than CURIEs
+var defaultIRISchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"ftp":    true,
+	"file":   true,
+	"urn":    true,
+	"did":    true,
+	"mailto": true,
+	"tag":    true,
+}
+
+// classifyIRI returns true if the value is an IRI. Values with an http or https scheme, or with an authority after
+// the scheme, are always IRIs. Values with another IRI scheme are IRIs unless the scheme is also declared as a prefix,
+// in which case the value is a CURIE.
+func classifyIRI(value string, isIRIScheme func(scheme string) bool, isDeclaredPrefix func(prefix string) bool) bool {
+	scheme, rest, ok := splitIRIScheme(value)
+	if !ok {
+		return false
+	}
+	if strings.EqualFold(scheme, "http") || strings.EqualFold(scheme, "https") || strings.HasPrefix(rest, "//") {
+		return true
+	}
+	if !isIRIScheme(strings.ToLower(scheme)) {
+		return false
+	}
+	return !isDeclaredPrefix(scheme)
+}
+
+// isHierarchicalIRI returns true if the IRI has a '/' or '#' that a namespace expansion can end with
+func isHierarchicalIRI(value string) bool {
+	return strings.LastIndexAny(value, "/#") > 0
+}
+
+// splitIRIScheme splits the value into the scheme and the rest, ok is false if the value does not start with a
+// valid scheme, that is a letter followed by letters, digits, '+', '-' or '.' and a ':'
+func splitIRIScheme(value string) (string, string, bool) {
+	colon := strings.Index(value, ":")
+	if colon <= 0 {
+		return "", "", false
+	}
+	for i := 0; i < colon; i++ {
+		c := value[i]
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if i == 0 && !isLetter {
+			return "", "", false
+		}
+		if !isLetter && !(c >= '0' && c <= '9') && c != '+' && c != '-' && c != '.' {
+			return "", "", false
+		}
+	}
+	return value[:colon], value[colon+1:], true
+}
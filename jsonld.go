@@ -3,13 +3,78 @@ package egdm
 import (
 	"encoding/json"
 	"io"
+	"strconv"
+	"strings"
 )
 
+const (
+	// JsonLDDeletedPredicate is the default predicate used for Entity.IsDeleted in JSON-LD
+	JsonLDDeletedPredicate = "core:deleted"
+	// JsonLDRecordedPredicate is the default predicate used for Entity.Recorded in JSON-LD
+	JsonLDRecordedPredicate = "core:recorded"
+	// JsonLDInternalIDPredicate is the default predicate used for Entity.InternalID in JSON-LD
+	JsonLDInternalIDPredicate = "core:internalId"
+
+	xsdUnsignedLong = XsdNamespaceExpansion + "unsignedLong"
+)
+
+// jsonLDDefaultPrefixes are the WellKnownPrefixes written in the context when no prefixes are configured
+var jsonLDDefaultPrefixes = []string{"core", "rdf"}
+
 type JsonLdRef struct {
 	ID string `json:"@id"`
 }
 
+// JsonLdValue is a JSON-LD value object. It can be used as a property value to write language tagged or datatyped
+// literals, and is the value JsonLDParser produces for value objects it can not map to a Go value.
+type JsonLdValue struct {
+	Value    any    `json:"@value"`
+	Type     string `json:"@type,omitempty"`
+	Language string `json:"@language,omitempty"`
+}
+
+// JsonLDWriter writes an EntityCollection as JSON-LD. The zero value writes the core and rdf prefixes into the context
+// and entity metadata under the JsonLDDeletedPredicate, JsonLDRecordedPredicate and JsonLDInternalIDPredicate predicates.
 type JsonLDWriter struct {
+	prefixes            map[string]string
+	typedLiterals       bool
+	deletedPredicate    string
+	recordedPredicate   string
+	internalIDPredicate string
+}
+
+func NewJsonLDWriter() *JsonLDWriter {
+	return &JsonLDWriter{}
+}
+
+// WithPrefixes sets the prefixes added to the namespace mappings of the collection in the written context,
+// replacing the default core and rdf prefixes. Prefixes that the collection already binds keep their expansion. The
+// well known prefixes of the metadata predicates and the continuation are declared even if they are not in the map,
+// when the collection binds core or rdf to another namespace the default predicates and the continuation are written
+// as full IRIs.
+func (jsonLDWriter *JsonLDWriter) WithPrefixes(prefixes map[string]string) *JsonLDWriter {
+	jsonLDWriter.prefixes = prefixes
+	return jsonLDWriter
+}
+
+// WithWellKnownPrefixes sets the prefixes added to the written context to the WellKnownPrefixes
+func (jsonLDWriter *JsonLDWriter) WithWellKnownPrefixes() *JsonLDWriter {
+	jsonLDWriter.prefixes = WellKnownPrefixes
+	return jsonLDWriter
+}
+
+// WithMetadataPredicates sets the predicates used for the deleted flag, the recorded time and the internal id of entities
+func (jsonLDWriter *JsonLDWriter) WithMetadataPredicates(deleted string, recorded string, internalID string) *JsonLDWriter {
+	jsonLDWriter.deletedPredicate = deleted
+	jsonLDWriter.recordedPredicate = recorded
+	jsonLDWriter.internalIDPredicate = internalID
+	return jsonLDWriter
+}
+
+// WithTypedLiterals configures the writer to write booleans and numbers as value objects with an explicit xsd datatype
+func (jsonLDWriter *JsonLDWriter) WithTypedLiterals() *JsonLDWriter {
+	jsonLDWriter.typedLiterals = true
+	return jsonLDWriter
 }
 
 func (jsonLDWriter *JsonLDWriter) Write(ec *EntityCollection, writer io.Writer) error {
@@ -23,7 +88,7 @@ func (jsonLDWriter *JsonLDWriter) Write(ec *EntityCollection, writer io.Writer)
 
 	// write context
 	mappings := ec.NamespaceManager.GetNamespaceMappings()
-	context := jsonLDWriter.makeContext(mappings)
+	context, terms := jsonLDWriter.makeContext(mappings, ec.Continuation != nil)
 	contextJson, _ := json.Marshal(context)
 	_, err = writer.Write(contextJson)
 	if err != nil {
@@ -36,7 +101,7 @@ func (jsonLDWriter *JsonLDWriter) Write(ec *EntityCollection, writer io.Writer)
 		if err != nil {
 			return err
 		}
-		entityLD := jsonLDWriter.toJSONLD(entity)
+		entityLD := jsonLDWriter.toJSONLD(entity, terms)
 		entityJson, err := json.Marshal(entityLD)
 		if err != nil {
 			return err
@@ -53,7 +118,7 @@ func (jsonLDWriter *JsonLDWriter) Write(ec *EntityCollection, writer io.Writer)
 		if err != nil {
 			return err
 		}
-		contToken := jsonLDWriter.makeContinuationToken(ec.Continuation.Token)
+		contToken := jsonLDWriter.makeContinuationToken(ec.Continuation.Token, terms)
 		_, err = writer.Write([]byte(contToken))
 		if err != nil {
 			return err
@@ -69,29 +134,97 @@ func (jsonLDWriter *JsonLDWriter) Write(ec *EntityCollection, writer io.Writer)
 	return nil
 }
 
-func (jsonLDWriter *JsonLDWriter) makeContext(namespaceMappings map[string]string) map[string]interface{} {
-	namespaces := make(map[string]string, len(namespaceMappings)+2)
+// jsonLDTerms are the keys and values written for the entity metadata and the continuation of one document
+type jsonLDTerms struct {
+	deleted      string
+	recorded     string
+	internalID   string
+	rdfType      string
+	continuation string
+	token        string
+}
+
+func (jsonLDWriter *JsonLDWriter) makeContext(namespaceMappings map[string]string, hasContinuation bool) (map[string]interface{}, jsonLDTerms) {
+	prefixes := jsonLDWriter.prefixes
+	if prefixes == nil {
+		prefixes = make(map[string]string, len(jsonLDDefaultPrefixes))
+		for _, prefix := range jsonLDDefaultPrefixes {
+			prefixes[prefix] = WellKnownPrefixes[prefix]
+		}
+	}
+
+	namespaces := make(map[string]string, len(namespaceMappings)+len(prefixes))
 	for k, v := range namespaceMappings {
 		namespaces[k] = v
 	}
-	namespaces["core"] = "http://data.mimiro.io/core/uda/"
-	namespaces["rdf"] = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	// the bindings of the collection are used by its identifiers, so they are never replaced
+	for k, v := range prefixes {
+		if _, bound := namespaces[k]; !bound {
+			namespaces[k] = v
+		}
+	}
+
+	// configured metadata predicates are written as they are, so the well known prefixes they use are declared
+	for _, predicate := range []string{jsonLDWriter.deletedPredicate, jsonLDWriter.recordedPredicate, jsonLDWriter.internalIDPredicate} {
+		prefix, _, isCURIE := strings.Cut(predicate, ":")
+		if !isCURIE {
+			continue
+		}
+		if _, bound := namespaces[prefix]; bound {
+			continue
+		}
+		if expansion, found := WellKnownPrefixes[prefix]; found {
+			namespaces[prefix] = expansion
+		}
+	}
+
+	terms := jsonLDTerms{
+		deleted:    jsonLDWriter.deletedPredicate,
+		recorded:   jsonLDWriter.recordedPredicate,
+		internalID: jsonLDWriter.internalIDPredicate,
+	}
+	if terms.deleted == "" {
+		terms.deleted = jsonLDTerm(namespaces, "core", CoreNamespaceExpansion, "deleted")
+	}
+	if terms.recorded == "" {
+		terms.recorded = jsonLDTerm(namespaces, "core", CoreNamespaceExpansion, "recorded")
+	}
+	if terms.internalID == "" {
+		terms.internalID = jsonLDTerm(namespaces, "core", CoreNamespaceExpansion, "internalId")
+	}
+	if hasContinuation {
+		terms.rdfType = jsonLDTerm(namespaces, "rdf", RdfNamespaceExpansion, "type")
+		terms.continuation = jsonLDTerm(namespaces, "core", CoreNamespaceExpansion, "continuation")
+		terms.token = jsonLDTerm(namespaces, "core", CoreNamespaceExpansion, "token")
+	}
 
 	jsonLdContext := make(map[string]interface{})
 	jsonLdContext["@context"] = namespaces
-	return jsonLdContext
+	return jsonLdContext, terms
+}
+
+// jsonLDTerm returns the CURIE of a term of the core or rdf namespace, declaring the prefix if it is not bound. When
+// the prefix is bound to another namespace the full IRI is returned.
+func jsonLDTerm(namespaces map[string]string, prefix string, expansion string, local string) string {
+	if _, bound := namespaces[prefix]; !bound {
+		namespaces[prefix] = expansion
+	}
+	if namespaces[prefix] == expansion {
+		return prefix + ":" + local
+	}
+	return expansion + local
 }
 
-func (jsonLDWriter *JsonLDWriter) makeContinuationToken(token string) string {
+func (jsonLDWriter *JsonLDWriter) makeContinuationToken(token string, terms jsonLDTerms) string {
 	contToken := make(map[string]interface{})
-	contToken["rdf:type"] = map[string]string{"@id": "core:continuation"}
-	contToken["core:token"] = token
+	contToken[terms.rdfType] = map[string]string{"@id": terms.continuation}
+	contToken[terms.token] = token
 	jsonData, _ := json.Marshal(contToken)
 	return string(jsonData)
 }
 
 // Entity to JSON-LD representation
-func (jsonLDWriter *JsonLDWriter) toJSONLD(entity *Entity) map[string]interface{} {
+func (jsonLDWriter *JsonLDWriter) toJSONLD(entity *Entity, terms jsonLDTerms) map[string]interface{} {
 	jsonLd := make(map[string]interface{})
 
 	// get the id and add that if not empty
@@ -99,32 +232,60 @@ func (jsonLDWriter *JsonLDWriter) toJSONLD(entity *Entity) map[string]interface{
 		jsonLd["@id"] = entity.ID
 	}
 
+	// write the metadata, the numbers are written as strings so that they survive JSON number handling
+	if entity.IsDeleted {
+		jsonLd[terms.deleted] = true
+	}
+	if entity.Recorded != 0 {
+		jsonLd[terms.recorded] = JsonLdValue{Value: strconv.FormatUint(entity.Recorded, 10), Type: xsdUnsignedLong}
+	}
+	if entity.InternalID != 0 {
+		jsonLd[terms.internalID] = JsonLdValue{Value: strconv.FormatUint(entity.InternalID, 10), Type: xsdUnsignedLong}
+	}
+
 	// get props
 	for key, value := range entity.Properties {
 		// check the type of value
 		switch v := value.(type) {
 		case []interface{}:
 			// array of values
-			jsonLd[key] = jsonLDWriter.toJSONLDFromArray(v)
+			jsonLd[key] = jsonLDWriter.toJSONLDFromArray(v, terms)
 		case *Entity:
 			// entity
-			jsonLd[key] = jsonLDWriter.toJSONLD(v)
+			jsonLd[key] = jsonLDWriter.toEmbeddedJSONLD(v, terms)
+		case []*Entity:
+			// array of entities, such as the embedded entities of an expanded collection
+			jsonLd[key] = jsonLDWriter.toJSONLDFromEntities(v, terms)
+		case map[string]interface{}:
+			// entity that has not been parsed into an Entity
+			jsonLd[key] = jsonLDWriter.toEmbeddedJSONLD(newEntityFromMap(v), terms)
 		default:
-			// assume we can just put out the value
-			jsonLd[key] = v
+			jsonLd[key] = jsonLDWriter.toJSONLDValue(v)
 		}
 	}
 
-	// get the refs
+	// get the refs, an empty array would be read back as a property so empty references are not written
 	for key, value := range entity.References {
 		// check the type of value
 		switch v := value.(type) {
 		case []string:
-			refs := make([]JsonLdRef, len(v))
+			refs := make([]JsonLdRef, 0, len(v))
 			for _, ref := range v {
 				refs = append(refs, JsonLdRef{ID: ref})
 			}
-			jsonLd[key] = refs
+			if len(refs) > 0 {
+				jsonLd[key] = refs
+			}
+		case []interface{}:
+			refs := make([]JsonLdRef, 0, len(v))
+			for _, ref := range v {
+				if refString, ok := ref.(string); ok {
+					refs = append(refs, JsonLdRef{ID: refString})
+				}
+			}
+			if len(refs) > 0 {
+				jsonLd[key] = refs
+			}
 		case string:
 			jsonLd[key] = JsonLdRef{ID: v}
 		}
@@ -133,19 +294,72 @@ func (jsonLDWriter *JsonLDWriter) toJSONLD(entity *Entity) map[string]interface{
 	return jsonLd
 }
 
-func (jsonLDWriter *JsonLDWriter) toJSONLDFromArray(entityArray []interface{}) []interface{} {
+// toEmbeddedJSONLD returns the JSON-LD of an embedded entity. A node object with only an @id is a reference, so an
+// embedded entity with only an id is written with an empty @type to keep it an entity when it is read back.
+func (jsonLDWriter *JsonLDWriter) toEmbeddedJSONLD(entity *Entity, terms jsonLDTerms) map[string]interface{} {
+	jsonLd := jsonLDWriter.toJSONLD(entity, terms)
+	if _, hasID := jsonLd["@id"]; hasID && len(jsonLd) == 1 {
+		jsonLd["@type"] = []string{}
+	}
+	return jsonLd
+}
+
+func (jsonLDWriter *JsonLDWriter) toJSONLDFromArray(entityArray []interface{}, terms jsonLDTerms) []interface{} {
 	jsonLd := make([]interface{}, len(entityArray))
 
 	for i, value := range entityArray {
 		switch v := value.(type) {
 		case []interface{}:
-			jsonLd[i] = jsonLDWriter.toJSONLDFromArray(v)
+			jsonLd[i] = jsonLDWriter.toJSONLDFromArray(v, terms)
 		case *Entity:
-			jsonLd[i] = jsonLDWriter.toJSONLD(v)
+			jsonLd[i] = jsonLDWriter.toEmbeddedJSONLD(v, terms)
+		case []*Entity:
+			jsonLd[i] = jsonLDWriter.toJSONLDFromEntities(v, terms)
+		case map[string]interface{}:
+			jsonLd[i] = jsonLDWriter.toEmbeddedJSONLD(newEntityFromMap(v), terms)
 		default:
-			jsonLd[i] = value
+			jsonLd[i] = jsonLDWriter.toJSONLDValue(value)
 		}
 	}
 
 	return jsonLd
 }
+
+func (jsonLDWriter *JsonLDWriter) toJSONLDFromEntities(entities []*Entity, terms jsonLDTerms) []interface{} {
+	jsonLd := make([]interface{}, len(entities))
+	for i, entity := range entities {
+		jsonLd[i] = jsonLDWriter.toEmbeddedJSONLD(entity, terms)
+	}
+	return jsonLd
+}
+
+// toJSONLDValue returns the value as is, or as a value object with an xsd datatype when typed literals are enabled
+func (jsonLDWriter *JsonLDWriter) toJSONLDValue(value any) any {
+	if !jsonLDWriter.typedLiterals {
+		return value
+	}
+	switch value.(type) {
+	case string, DateTime, Date, Decimal, LangString, Binary, JsonLdValue:
+		// strings and the typed literal values are written as they are
+		return value
+	}
+	if _, datatype, err := literalOf(value); err == nil {
+		return JsonLdValue{Value: value, Type: datatype}
+	}
+	return value
+}
+
+// jsonLDMetadataPredicates returns the given metadata predicates with the defaults applied for the ones not set. The
+// defaults are full IRIs so that they do not depend on the prefixes a document binds.
+func jsonLDMetadataPredicates(deleted string, recorded string, internalID string) (string, string, string) {
+	if deleted == "" {
+		deleted = CoreNamespaceExpansion + "deleted"
+	}
+	if recorded == "" {
+		recorded = CoreNamespaceExpansion + "recorded"
+	}
+	if internalID == "" {
+		internalID = CoreNamespaceExpansion + "internalId"
+	}
+	return deleted, recorded, internalID
+}
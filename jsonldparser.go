@@ -0,0 +1,654 @@
+package egdm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// JsonLDParser reads JSON-LD documents into entities. It understands @context prefix maps, @id, reference objects,
+// @graph, @type and nested node objects. Documents written by JsonLDWriter, which are an array of a context object
+// followed by node objects and an optional continuation object, can be read back without loss.
+type JsonLDParser struct {
+	nsManager           NamespaceManager
+	expandURIs          bool
+	compressURIs        bool
+	deletedPredicate    string
+	recordedPredicate   string
+	internalIDPredicate string
+
+	prefixConflictPolicy PrefixConflictPolicy
+}
+
+// jsonLDDocument holds the state of one call to Parse, so that a JsonLDParser can be used for concurrent calls
+type jsonLDDocument struct {
+	*JsonLDParser
+	identities         *EntityParser
+	metadataPredicates map[string]string
+	// terms maps the terms defined in the contexts of the document to the IRIs they stand for
+	terms map[string]string
+	// prefixRenames maps the prefixes of the document that were renamed in the namespace manager to their new prefix
+	prefixRenames map[string]string
+}
+
+func NewJsonLDParser(nsManager NamespaceManager) *JsonLDParser {
+	jp := &JsonLDParser{}
+	// default to inbuilt namespace manager if not defined
+	if nsManager == nil {
+		nsManager = NewNamespaceContext()
+	}
+	jp.nsManager = nsManager
+	return jp
+}
+
+func (jp *JsonLDParser) WithExpandURIs() *JsonLDParser {
+	jp.expandURIs = true
+	return jp
+}
+
+func (jp *JsonLDParser) WithCompressURIs() *JsonLDParser {
+	jp.compressURIs = true
+	return jp
+}
+
+// WithMetadataPredicates sets the predicates read into the deleted flag, the recorded time and the internal id of
+// entities. They must match the predicates the document was written with.
+func (jp *JsonLDParser) WithMetadataPredicates(deleted string, recorded string, internalID string) *JsonLDParser {
+	jp.deletedPredicate = deleted
+	jp.recordedPredicate = recorded
+	jp.internalIDPredicate = internalID
+	return jp
+}
+
+// WithPrefixConflictPolicy sets the policy used when a prefix in an @context is already bound to a different expansion
+// in the namespace manager. With PrefixConflictRename the identifiers in the document that use the prefix are rewritten
+// to the prefix the expansion was bound to.
+func (jp *JsonLDParser) WithPrefixConflictPolicy(policy PrefixConflictPolicy) *JsonLDParser {
+	jp.prefixConflictPolicy = policy
+	return jp
+}
+
+func (jp *JsonLDParser) GetNamespaceManager() NamespaceManager {
+	return jp.nsManager
+}
+
+func (jp *JsonLDParser) LoadEntityCollection(reader io.Reader) (*EntityCollection, error) {
+	return jp.LoadEntityCollectionContext(context.Background(), reader)
+}
+
+// LoadEntityCollectionContext is LoadEntityCollection but returns ctx.Err() when the context is done
+func (jp *JsonLDParser) LoadEntityCollectionContext(ctx context.Context, reader io.Reader) (*EntityCollection, error) {
+	ec := NewEntityCollection(jp.nsManager)
+	err := jp.ParseContext(ctx, reader, func(e *Entity) error {
+		return ec.AddEntity(e)
+	}, func(c *Continuation) {
+		ec.SetContinuationToken(c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ec, nil
+}
+
+// ParseContext is Parse but checks the context between entities and returns ctx.Err() when it is done
+func (jp *JsonLDParser) ParseContext(ctx context.Context, reader io.Reader, emitEntity func(*Entity) error, emitContinuation func(*Continuation)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return jp.Parse(reader, emitWhileNotDone(ctx, emitEntity), emitContinuation)
+}
+
+func (jp *JsonLDParser) Parse(reader io.Reader, emitEntity func(*Entity) error, emitContinuation func(*Continuation)) error {
+	// identifiers are checked and rewritten in the same way as in Entity Graph JSON
+	doc := &jsonLDDocument{JsonLDParser: jp, identities: NewEntityParser(jp.nsManager)}
+	doc.identities.expandURIs = jp.expandURIs
+	doc.identities.compressURIs = jp.compressURIs
+
+	deleted, recorded, internalID := jsonLDMetadataPredicates(jp.deletedPredicate, jp.recordedPredicate, jp.internalIDPredicate)
+	doc.metadataPredicates = map[string]string{"deleted": deleted, "recorded": recorded, "internalId": internalID}
+
+	bufferedReader := bufio.NewReader(reader)
+	start, err := peekFirstNonSpace(bufferedReader)
+	if err != nil {
+		return fmt.Errorf("parsing error: Bad token at start of stream: %w", err)
+	}
+	decoder := json.NewDecoder(bufferedReader)
+	// numbers are decoded as json.Number so integers above 2^53 keep their precision
+	decoder.UseNumber()
+
+	switch start {
+	case '{':
+		node := make(map[string]any)
+		err = decoder.Decode(&node)
+		if err != nil {
+			return fmt.Errorf("parsing error: Unable to decode JSON-LD document: %w", err)
+		}
+		return doc.parseTopLevelNode(node, emitEntity, emitContinuation)
+	case '[':
+		// read the array one node at a time
+		_, err = decoder.Token()
+		if err != nil {
+			return fmt.Errorf("parsing error: Bad token at start of stream: %w", err)
+		}
+		for decoder.More() {
+			node := make(map[string]any)
+			err = decoder.Decode(&node)
+			if err != nil {
+				return fmt.Errorf("parsing error: Unable to decode JSON-LD node: %w", err)
+			}
+			err = doc.parseTopLevelNode(node, emitEntity, emitContinuation)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		if err != nil {
+			return fmt.Errorf("parsing error: Unable to read end of array: %w", err)
+		}
+		return nil
+	}
+
+	return errors.New("parsing error: Expected [ or { at start of document")
+}
+
+func (doc *jsonLDDocument) parseTopLevelNode(node map[string]any, emitEntity func(*Entity) error, emitContinuation func(*Continuation)) error {
+	if context, found := node["@context"]; found {
+		err := doc.parseContext(context)
+		if err != nil {
+			return err
+		}
+	}
+
+	if graph, found := node["@graph"]; found {
+		nodes, ok := graph.([]any)
+		if !ok {
+			nodes = []any{graph}
+		}
+		for _, n := range nodes {
+			graphNode, ok := n.(map[string]any)
+			if !ok {
+				return errors.New("parsing error: @graph must contain node objects")
+			}
+			err := doc.parseTopLevelNode(graphNode, emitEntity, emitContinuation)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !hasNodeContent(node) {
+		return nil
+	}
+
+	if isContinuation, token := doc.continuationToken(node); isContinuation {
+		if emitContinuation != nil {
+			continuation := NewContinuation()
+			continuation.Token = token
+			emitContinuation(continuation)
+		}
+		return nil
+	}
+
+	entity, err := doc.parseNode(node)
+	if err != nil {
+		return err
+	}
+	return emitEntity(entity)
+}
+
+// parseContext stores the prefixes of a context. Definitions whose IRI ends with one of the characters that end a
+// namespace, such as / or #, are stored as prefixes and @vocab is stored as the default namespace. Other definitions,
+// such as "name": "http://schema.org/name", are terms that stand for the whole IRI in keys and types of the document.
+// Keyword aliases, type coercion and remote contexts are not supported.
+func (doc *jsonLDDocument) parseContext(context any) error {
+	switch v := context.(type) {
+	case nil:
+		return nil
+	case []any:
+		for _, c := range v {
+			err := doc.parseContext(c)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		// the terms are stored in order so that renamed prefixes do not depend on map iteration
+		for _, term := range sortedKeys(v) {
+			definition := v[term]
+			expansion := ""
+			switch d := definition.(type) {
+			case string:
+				expansion = d
+			case map[string]any:
+				expansion, _ = d["@id"].(string)
+			}
+			if expansion == "" {
+				continue
+			}
+			if term == "@vocab" {
+				term = "_"
+			} else if strings.HasPrefix(term, "@") || strings.HasPrefix(expansion, "@") {
+				continue
+			} else if !strings.ContainsAny(expansion[len(expansion)-1:], ":/?#[]@") {
+				if doc.terms == nil {
+					doc.terms = make(map[string]string)
+				}
+				doc.terms[term] = expansion
+				continue
+			}
+			err := doc.storePrefix(term, expansion)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case string:
+		return fmt.Errorf("parsing error: remote context %s is not supported", v)
+	}
+	return errors.New("parsing error: Unable to parse @context")
+}
+
+// storePrefix stores the prefix with the conflict policy and records a renamed prefix so that the identifiers of the
+// document are rewritten to it
+func (doc *jsonLDDocument) storePrefix(prefix string, expansion string) error {
+	conflict, err := storePrefixExpansionMappingWithPolicy(doc.nsManager, prefix, expansion, doc.prefixConflictPolicy)
+	if err != nil {
+		return fmt.Errorf("parsing error: unable to store namespace mapping: %w", err)
+	}
+	if conflict != nil && conflict.ResolvedPrefix != "" && conflict.ResolvedPrefix != prefix {
+		if doc.prefixRenames == nil {
+			doc.prefixRenames = make(map[string]string)
+		}
+		doc.prefixRenames[prefix] = conflict.ResolvedPrefix
+	}
+	return nil
+}
+
+// term returns the IRI of a term defined in the context, or the value itself if it is not a term
+func (doc *jsonLDDocument) term(value string) string {
+	if iri, found := doc.terms[value]; found {
+		return iri
+	}
+	return value
+}
+
+// continuationToken detects the continuation object written by JsonLDWriter
+func (doc *jsonLDDocument) continuationToken(node map[string]any) (bool, string) {
+	isContinuation := false
+	token := ""
+	for key, value := range node {
+		if key == "@context" {
+			continue
+		}
+		fullKey, err := doc.fullURI(key)
+		if err != nil {
+			return false, ""
+		}
+		switch fullKey {
+		case RdfTypeURI:
+			ref, ok := value.(map[string]any)
+			if !ok {
+				return false, ""
+			}
+			id, _ := ref["@id"].(string)
+			if fullID, err := doc.fullURI(id); err != nil || fullID != CoreNamespaceExpansion+"continuation" {
+				return false, ""
+			}
+			isContinuation = true
+		case CoreNamespaceExpansion + "token":
+			token, _ = value.(string)
+		default:
+			return false, ""
+		}
+	}
+	return isContinuation, token
+}
+
+func (doc *jsonLDDocument) parseNode(node map[string]any) (*Entity, error) {
+	entity := NewEntity()
+	// the keys are read in order so that the result does not depend on map iteration
+	for _, key := range sortedKeys(node) {
+		value := node[key]
+		switch key {
+		case "@id":
+			id, ok := value.(string)
+			if !ok {
+				return nil, errors.New("parsing error: @id must be a string")
+			}
+			identity, err := doc.identities.identityValue(id, doc.prefixRenames)
+			if err != nil {
+				return nil, err
+			}
+			entity.ID = identity
+		case "@type":
+			refs, err := doc.parseType(value)
+			if err != nil {
+				return nil, err
+			}
+			// an empty @type marks an embedded entity that only has an id
+			if types, isList := refs.([]string); isList && len(types) == 0 {
+				continue
+			}
+			typeKey, err := doc.identities.identityValue(RdfTypeURI, doc.prefixRenames)
+			if err != nil {
+				return nil, err
+			}
+			// the types are merged with the values of an rdf:type key of the same node
+			addReference(entity, typeKey, refs)
+		default:
+			// other keywords such as @context and @index carry no entity data
+			if strings.HasPrefix(key, "@") {
+				continue
+			}
+			key = doc.term(key)
+			isMetadata, err := doc.parseMetadata(entity, key, value)
+			if err != nil {
+				return nil, err
+			}
+			if isMetadata {
+				continue
+			}
+			identity, err := doc.identities.identityValue(key, doc.prefixRenames)
+			if err != nil {
+				return nil, err
+			}
+			err = doc.parseNodeValue(entity, identity, value)
+			if err != nil {
+				return nil, fmt.Errorf("parsing error: Unable to parse value of key %s: %w", key, err)
+			}
+		}
+	}
+	return entity, nil
+}
+
+// parseMetadata reads the value of the key into the entity metadata if the key is one of the metadata predicates
+func (doc *jsonLDDocument) parseMetadata(entity *Entity, key string, value any) (bool, error) {
+	fullKey, err := doc.fullURI(key)
+	if err != nil {
+		return false, nil
+	}
+
+	for field, predicate := range doc.metadataPredicates {
+		fullPredicate, err := doc.fullURI(predicate)
+		if err != nil || fullPredicate != fullKey {
+			continue
+		}
+
+		literal, err := doc.parseValue(value)
+		if err != nil {
+			return false, err
+		}
+		switch field {
+		case "deleted":
+			isDeleted, ok := literal.(bool)
+			if !ok {
+				return false, fmt.Errorf("parsing error: value of %s must be a boolean", key)
+			}
+			entity.IsDeleted = isDeleted
+		case "recorded":
+			entity.Recorded, err = jsonLDUint(literal)
+		case "internalId":
+			entity.InternalID, err = jsonLDUint(literal)
+		}
+		if err != nil {
+			return false, fmt.Errorf("parsing error: value of %s must be an unsigned integer: %w", key, err)
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (doc *jsonLDDocument) parseType(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return doc.identities.identityValue(doc.term(v), doc.prefixRenames)
+	case []any:
+		refs := make([]string, 0, len(v))
+		for _, t := range v {
+			s, ok := t.(string)
+			if !ok {
+				return nil, errors.New("parsing error: @type must be a string or an array of strings")
+			}
+			ref, err := doc.identities.identityValue(doc.term(s), doc.prefixRenames)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, ref)
+		}
+		return refs, nil
+	}
+	return nil, errors.New("parsing error: @type must be a string or an array of strings")
+}
+
+// parseNodeValue adds the value of a key to the entity. Reference objects become references, everything else
+// becomes a property. Arrays that mix references and other values are split between references and properties.
+func (doc *jsonLDDocument) parseNodeValue(entity *Entity, key string, value any) error {
+	values, isArray := value.([]any)
+	if !isArray {
+		if list, ok := value.(map[string]any); ok {
+			values, isArray = list["@list"].([]any)
+		}
+	}
+
+	if !isArray {
+		ref, isRef, err := doc.parseReference(value)
+		if err != nil {
+			return err
+		}
+		if isRef {
+			addReference(entity, key, ref)
+			return nil
+		}
+		prop, err := doc.parseValue(value)
+		if err != nil {
+			return err
+		}
+		entity.Properties[key] = prop
+		return nil
+	}
+
+	var refs []string
+	var props []any
+	for _, v := range values {
+		ref, isRef, err := doc.parseReference(v)
+		if err != nil {
+			return err
+		}
+		if isRef {
+			refs = append(refs, ref)
+			continue
+		}
+		prop, err := doc.parseValue(v)
+		if err != nil {
+			return err
+		}
+		props = append(props, prop)
+	}
+
+	if refs != nil {
+		addReference(entity, key, refs)
+	}
+	if props != nil || refs == nil {
+		if props == nil {
+			props = make([]any, 0)
+		}
+		entity.Properties[key] = props
+	}
+	return nil
+}
+
+// addReference adds references to the entity, merging them with the references the entity already has for the key
+func addReference(entity *Entity, key string, refs any) {
+	if existing, found := entity.References[key]; found {
+		refs = mergeValues(existing, refs, true)
+	}
+	entity.References[key] = refs
+}
+
+// parseReference returns the identity of a node object that only has an @id
+func (doc *jsonLDDocument) parseReference(value any) (string, bool, error) {
+	node, ok := value.(map[string]any)
+	if !ok || len(node) != 1 {
+		return "", false, nil
+	}
+	id, ok := node["@id"].(string)
+	if !ok {
+		return "", false, nil
+	}
+	ref, err := doc.identities.identityValue(id, doc.prefixRenames)
+	if err != nil {
+		return "", false, err
+	}
+	return ref, true, nil
+}
+
+func (doc *jsonLDDocument) parseValue(value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		if _, found := v["@value"]; found {
+			return doc.parseValueObject(v), nil
+		}
+		return doc.parseNode(v)
+	case []any:
+		values := make([]any, len(v))
+		for i, val := range v {
+			parsed, err := doc.parseValue(val)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = parsed
+		}
+		return values, nil
+	case json.Number:
+		return jsonLDNumber(v), nil
+	}
+	return value, nil
+}
+
+// maxExactFloatInteger is 2^53, float64 holds every integer up to it exactly
+const maxExactFloatInteger = 1 << 53
+
+// jsonLDNumber returns a number as float64, as the EntityParser reads numbers by default, unless it is an integer
+// that float64 can not hold exactly. Those are kept as json.Number.
+func jsonLDNumber(number json.Number) any {
+	if i, err := strconv.ParseInt(string(number), 10, 64); err == nil {
+		if i > maxExactFloatInteger || i < -maxExactFloatInteger {
+			return number
+		}
+		return float64(i)
+	}
+	if !strings.ContainsAny(string(number), ".eE") {
+		return number
+	}
+	if f, err := number.Float64(); err == nil {
+		return f
+	}
+	return number
+}
+
+// parseValueObject returns the Go value of a value object. Values with a known xsd datatype are converted like RDF
+// literals and language tagged strings are returned as LangString, as the EntityParser reads them. Values of other
+// datatypes are returned as JsonLdValue.
+func (doc *jsonLDDocument) parseValueObject(valueObject map[string]any) any {
+	value := valueObject["@value"]
+	if language, ok := valueObject["@language"].(string); ok {
+		// language tagged strings are read as the EntityParser reads them
+		if lexical, isString := value.(string); isString {
+			return LangString{Value: lexical, Language: language}
+		}
+		return JsonLdValue{Value: value, Language: language}
+	}
+
+	datatype, ok := valueObject["@type"].(string)
+	if !ok {
+		return value
+	}
+	fullDatatype, err := doc.fullURI(datatype)
+	if err != nil {
+		return JsonLdValue{Value: value, Type: datatype}
+	}
+
+	var lexical string
+	switch v := value.(type) {
+	case string:
+		lexical = v
+	case bool:
+		lexical = strconv.FormatBool(v)
+	case json.Number:
+		lexical = string(v)
+	default:
+		return JsonLdValue{Value: value, Type: datatype}
+	}
+
+	literal := literalValue(rdfTerm{kind: rdfLiteral, value: lexical, datatype: fullDatatype})
+	if _, isValueObject := literal.(JsonLdValue); isValueObject {
+		return JsonLdValue{Value: value, Type: datatype}
+	}
+	return literal
+}
+
+func (doc *jsonLDDocument) fullURI(value string) (string, error) {
+	if value == "@type" {
+		return RdfTypeURI, nil
+	}
+	return doc.nsManager.GetFullURI(renamePrefix(doc.nsManager, doc.prefixRenames, doc.term(value)))
+}
+
+func jsonLDUint(value any) (uint64, error) {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return 0, fmt.Errorf("%d is negative", v)
+		}
+		return uint64(v), nil
+	case float64:
+		if v < 0 || v != math.Trunc(v) || v >= math.MaxUint64 {
+			return 0, fmt.Errorf("%v is not an unsigned integer", v)
+		}
+		return uint64(v), nil
+	case string:
+		return strconv.ParseUint(v, 10, 64)
+	case json.Number:
+		return strconv.ParseUint(string(v), 10, 64)
+	case JsonLdValue:
+		return jsonLDUint(v.Value)
+	}
+	return 0, fmt.Errorf("unexpected type %T", value)
+}
+
+// hasNodeContent returns false for objects that only carry a context
+func hasNodeContent(node map[string]any) bool {
+	for key := range node {
+		if key != "@context" {
+			return true
+		}
+	}
+	return false
+}
+
+func peekFirstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\n', '\r':
+			_, err = reader.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+		default:
+			return b[0], nil
+		}
+	}
+}
@@ -0,0 +1,583 @@
+package egdm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseJSONLDGraph(t *testing.T) {
+	reader := strings.NewReader(`{
+		"@context": {
+			"ex": "http://example.com/",
+			"@vocab": "http://example.com/vocab/",
+			"knows": {"@id": "http://example.com/knows", "@type": "@id"}
+		},
+		"@graph": [
+			{
+				"@id": "ex:1",
+				"@type": ["ex:Person", "ex:Agent"],
+				"name": "John Smith",
+				"ex:age": 42,
+				"ex:friend": {"@id": "ex:2"},
+				"ex:tags": ["a", "b"],
+				"ex:nickname": {"@value": "Johnny", "@language": "en"},
+				"ex:address": {
+					"ex:street": "Main Street",
+					"ex:country": {"@id": "ex:norway"}
+				},
+				"ex:related": [{"@id": "ex:3"}, {"@id": "ex:4"}],
+				"knows": {"@id": "ex:5"}
+			},
+			{
+				"@id": "http://example.com/2",
+				"@type": "ex:Person"
+			}
+		]
+	}`)
+
+	nsManager := NewNamespaceContext()
+	ec, err := NewJsonLDParser(nsManager).WithExpandURIs().LoadEntityCollection(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ec.Entities) != 2 {
+		t.Fatalf("expected 2 entities, got %d", len(ec.Entities))
+	}
+
+	john := ec.Entities[0]
+	if john.ID != "http://example.com/1" {
+		t.Errorf("unexpected id %s", john.ID)
+	}
+	types, _ := john.GetReferenceValues(RdfTypeURI)
+	if len(types) != 2 || types[0] != "http://example.com/Person" {
+		t.Errorf("unexpected types %v", types)
+	}
+	if john.Properties["http://example.com/vocab/name"] != "John Smith" {
+		t.Errorf("expected @vocab to be used for terms, got %v", john.Properties)
+	}
+	if john.Properties["http://example.com/age"] != 42.0 {
+		t.Errorf("unexpected age %v", john.Properties["http://example.com/age"])
+	}
+	if john.References["http://example.com/friend"] != "http://example.com/2" {
+		t.Errorf("unexpected friend %v", john.References["http://example.com/friend"])
+	}
+	if john.Properties["http://example.com/nickname"] != (LangString{Value: "Johnny", Language: "en"}) {
+		t.Errorf("unexpected nickname %v", john.Properties["http://example.com/nickname"])
+	}
+	address, ok := john.Properties["http://example.com/address"].(*Entity)
+	if !ok || address.Properties["http://example.com/street"] != "Main Street" || address.References["http://example.com/country"] != "http://example.com/norway" {
+		t.Errorf("unexpected address %v", john.Properties["http://example.com/address"])
+	}
+	related, _ := john.GetReferenceValues("http://example.com/related")
+	if len(related) != 2 || related[1] != "http://example.com/4" {
+		t.Errorf("unexpected related %v", related)
+	}
+	if ec.Entities[1].References[RdfTypeURI] != "http://example.com/Person" {
+		t.Errorf("unexpected type %v", ec.Entities[1].References)
+	}
+	if john.References["http://example.com/knows"] != "http://example.com/5" {
+		t.Errorf("expected term to be used for its IRI, got %v", john.References)
+	}
+	if _, err := nsManager.GetNamespaceExpansionForPrefix("knows"); err == nil {
+		t.Errorf("expected term definition not to be stored as a prefix")
+	}
+}
+
+func TestParseJSONLDRoundTrip(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	address := NewEntity().SetID("ex:address1")
+	address.SetProperty("ex:street", "Main Street")
+	address.SetReference("ex:country", "ex:norway")
+
+	entity := NewEntity().SetID("ex:1")
+	entity.SetProperty("ex:name", "John Smith")
+	entity.SetProperty("ex:age", 42.0)
+	entity.SetProperty("ex:active", true)
+	entity.SetProperty("ex:tags", []any{"a", "b"})
+	entity.SetProperty("ex:address", address)
+	entity.SetProperty("ex:previous", []any{NewEntity().SetProperty("ex:street", "Old Street")})
+	entity.SetReference("ex:friend", "ex:2")
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+	continuation := NewContinuation()
+	continuation.Token = "1234"
+	ec.SetContinuationToken(continuation)
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteJSON_LD(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewJsonLDParser(NewNamespaceContext()).LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Entities) != 1 {
+		t.Fatalf("expected 1 entity, got %d", len(parsed.Entities))
+	}
+	result := parsed.Entities[0]
+	if result.ID != "ex:1" || result.Properties["ex:name"] != "John Smith" || result.Properties["ex:age"] != 42.0 || result.Properties["ex:active"] != true {
+		t.Errorf("unexpected entity %v", result)
+	}
+	if tags, ok := result.Properties["ex:tags"].([]any); !ok || len(tags) != 2 || tags[1] != "b" {
+		t.Errorf("unexpected tags %v", result.Properties["ex:tags"])
+	}
+	parsedAddress, ok := result.Properties["ex:address"].(*Entity)
+	if !ok || parsedAddress.ID != "ex:address1" || parsedAddress.Properties["ex:street"] != "Main Street" || parsedAddress.References["ex:country"] != "ex:norway" {
+		t.Errorf("unexpected address %v", result.Properties["ex:address"])
+	}
+	previous, ok := result.Properties["ex:previous"].([]any)
+	if !ok || len(previous) != 1 || previous[0].(*Entity).Properties["ex:street"] != "Old Street" {
+		t.Errorf("unexpected previous %v", result.Properties["ex:previous"])
+	}
+	if result.References["ex:friend"] != "ex:2" {
+		t.Errorf("unexpected friend %v", result.References["ex:friend"])
+	}
+	if parsed.Continuation == nil || parsed.Continuation.Token != "1234" {
+		t.Errorf("expected continuation token 1234, got %v", parsed.Continuation)
+	}
+}
+
+func TestJSONLDRoundTripOfEmbeddedEntityShapes(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	entity := NewEntity().SetID("ex:1")
+	entity.SetProperty("ex:addresses", []*Entity{NewEntity().SetProperty("ex:street", "Main Street")})
+	entity.SetProperty("ex:owner", map[string]any{"id": "ex:2", "props": map[string]any{"ex:name": "Jane"}})
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+	// expanding the collection stores embedded entities as []*Entity
+	if err := ec.ExpandNamespacePrefixes(); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteJSON_LD(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewJsonLDParser(NewNamespaceContext()).LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := parsed.Entities[0]
+	addresses, ok := result.Properties["http://example.com/addresses"].([]any)
+	if !ok || len(addresses) != 1 || addresses[0].(*Entity).Properties["http://example.com/street"] != "Main Street" {
+		t.Errorf("unexpected addresses %#v", result.Properties["http://example.com/addresses"])
+	}
+	owner, ok := result.Properties["http://example.com/owner"].(*Entity)
+	if !ok || owner.ID != "http://example.com/2" || owner.Properties["http://example.com/name"] != "Jane" {
+		t.Errorf("unexpected owner %#v", result.Properties["http://example.com/owner"])
+	}
+}
+
+func TestParseJSONLDMergesTypeKeys(t *testing.T) {
+	data := `{"@context":{"ex":"http://example.com/","rdf":"http://www.w3.org/1999/02/22-rdf-syntax-ns#","Person":"http://example.com/Person"},
+		"@id":"ex:1","@type":["Person","ex:Agent"],"rdf:type":[{"@id":"ex:Thing"},{"@id":"ex:Agent"}]}`
+
+	for i := 0; i < 10; i++ {
+		ec, err := NewJsonLDParser(NewNamespaceContext()).WithExpandURIs().LoadEntityCollection(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		types, err := ec.Entities[0].GetReferenceValues(RdfTypeURI)
+		expected := []string{"http://example.com/Person", "http://example.com/Agent", "http://example.com/Thing"}
+		if err != nil || !reflect.DeepEqual(types, expected) {
+			t.Fatalf("expected %v, got %v %v", expected, types, err)
+		}
+	}
+}
+
+func TestJSONLDRoundTripOfEmbeddedEntitiesWithOnlyAnID(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	entity := NewEntity().SetID("ex:1")
+	entity.SetProperty("ex:emb", NewEntity().SetID("ex:2"))
+	entity.SetProperty("ex:list", []*Entity{NewEntity().SetID("ex:3")})
+	entity.SetReference("ex:ref", "ex:4")
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+	buffer := bytes.Buffer{}
+	if err := ec.WriteJSON_LD(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewJsonLDParser(NewNamespaceContext()).LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := parsed.Entities[0]
+	if embedded, ok := result.Properties["ex:emb"].(*Entity); !ok || embedded.ID != "ex:2" || len(embedded.References) != 0 {
+		t.Errorf("expected embedded entity, got %#v", result.Properties["ex:emb"])
+	}
+	if list, ok := result.Properties["ex:list"].([]any); !ok || len(list) != 1 || list[0].(*Entity).ID != "ex:3" {
+		t.Errorf("expected embedded entities, got %#v", result.Properties["ex:list"])
+	}
+	if result.References["ex:ref"] != "ex:4" || len(result.References) != 1 {
+		t.Errorf("unexpected references %v", result.References)
+	}
+}
+
+func TestParseJSONLDFailsForUnknownPrefix(t *testing.T) {
+	reader := strings.NewReader(`[{"@context": {}}, {"@id": "ex:1"}]`)
+	_, err := NewJsonLDParser(nil).LoadEntityCollection(reader)
+	if err == nil {
+		t.Error("expected error for unknown prefix")
+	}
+}
+
+func TestParseJSONLDLargeIntegers(t *testing.T) {
+	reader := strings.NewReader(`[{"@context": {"ex": "http://example.com/", "core": "http://data.mimiro.io/core/uda/"}},
+		{"@id": "ex:1", "ex:serial": 9007199254740993, "ex:age": 42, "core:recorded": 1730979552787404545}]`)
+	ec, err := NewJsonLDParser(nil).LoadEntityCollection(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity := ec.Entities[0]
+	if entity.Properties["ex:serial"] != json.Number("9007199254740993") {
+		t.Errorf("expected integer above 2^53 to keep its precision, got %#v", entity.Properties["ex:serial"])
+	}
+	if entity.Properties["ex:age"] != 42.0 {
+		t.Errorf("expected small integer to be read as float64, got %#v", entity.Properties["ex:age"])
+	}
+	if entity.Recorded != 1730979552787404545 {
+		t.Errorf("expected recorded to keep its precision, got %d", entity.Recorded)
+	}
+}
+
+func TestParseJSONLDRejectsInvalidMetadataNumbers(t *testing.T) {
+	for _, value := range []string{"-1", "1.5", `{"@value": -1, "@type": "xsd:long"}`} {
+		reader := strings.NewReader(`[{"@context": {"ex": "http://example.com/", "core": "http://data.mimiro.io/core/uda/",
+			"xsd": "http://www.w3.org/2001/XMLSchema#"}}, {"@id": "ex:1", "core:recorded": ` + value + `}]`)
+		if _, err := NewJsonLDParser(nil).LoadEntityCollection(reader); err == nil {
+			t.Errorf("expected error for recorded %s", value)
+		}
+	}
+}
+
+func TestJSONLDRoundTripOfAllEntityFields(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	entity := NewEntity().SetID("ex:1")
+	entity.InternalID = 18446744073709551615
+	entity.Recorded = 1730979552787404544
+	entity.IsDeleted = true
+	entity.SetProperty("ex:name", JsonLdValue{Value: "Jean", Language: "fr"})
+	entity.SetProperty("ex:code", JsonLdValue{Value: "A-1", Type: "ex:Code"})
+	entity.SetProperty("ex:count", 3)
+	entity.SetReference("ex:knows", []string{"ex:2", "ex:3"})
+	entity.SetReference("ex:likes", []any{"ex:4"})
+	entity.SetReference("ex:parent", "ex:5")
+	entity.SetReference("ex:children", []string{})
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := NewJsonLDWriter().WithTypedLiterals().Write(ec, &buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewJsonLDParser(NewNamespaceContext()).LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := parsed.Entities[0]
+	if result.ID != "ex:1" {
+		t.Errorf("unexpected id %s", result.ID)
+	}
+	if result.InternalID != entity.InternalID {
+		t.Errorf("expected internal id %d, got %d", entity.InternalID, result.InternalID)
+	}
+	if result.Recorded != entity.Recorded {
+		t.Errorf("expected recorded %d, got %d", entity.Recorded, result.Recorded)
+	}
+	if !result.IsDeleted {
+		t.Error("expected entity to be deleted")
+	}
+	if result.Properties["ex:name"] != (LangString{Value: "Jean", Language: "fr"}) {
+		t.Errorf("unexpected name %v", result.Properties["ex:name"])
+	}
+	if result.Properties["ex:code"] != (JsonLdValue{Value: "A-1", Type: "ex:Code"}) {
+		t.Errorf("unexpected code %v", result.Properties["ex:code"])
+	}
+	if result.Properties["ex:count"] != 3 {
+		t.Errorf("expected typed integer to be read as int, got %T %v", result.Properties["ex:count"], result.Properties["ex:count"])
+	}
+	if len(result.Properties) != 3 {
+		t.Errorf("expected metadata to not be read as properties, got %v", result.Properties)
+	}
+	knows, _ := result.GetReferenceValues("ex:knows")
+	if len(knows) != 2 || knows[0] != "ex:2" || knows[1] != "ex:3" {
+		t.Errorf("unexpected knows %v", knows)
+	}
+	likes, _ := result.GetReferenceValues("ex:likes")
+	if len(likes) != 1 || likes[0] != "ex:4" {
+		t.Errorf("unexpected likes %v", likes)
+	}
+	if result.References["ex:parent"] != "ex:5" {
+		t.Errorf("unexpected parent %v", result.References["ex:parent"])
+	}
+	if _, found := result.Properties["ex:children"]; found {
+		t.Errorf("expected empty references to not be read as a property, got %v", result.Properties["ex:children"])
+	}
+}
+
+func TestJSONLDRoundTripOfLiteralTypes(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	born := time.Date(1981, 5, 17, 8, 0, 0, 0, time.UTC)
+	entity := NewEntity().SetID("ex:1")
+	entity.SetProperty("ex:price", Decimal("1.10"))
+	entity.SetProperty("ex:name", LangString{Value: "Jean", Language: "fr"})
+	entity.SetProperty("ex:born", DateTime{born})
+	entity.SetProperty("ex:day", Date{born.Truncate(24 * time.Hour)})
+	entity.SetProperty("ex:data", Binary{1, 2, 3})
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteJSON_LD(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewJsonLDParser(NewNamespaceContext()).LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := parsed.Entities[0].Properties
+	if props["ex:price"] != Decimal("1.10") {
+		t.Errorf("expected decimal to keep its lexical form, got %#v", props["ex:price"])
+	}
+	if props["ex:name"] != (LangString{Value: "Jean", Language: "fr"}) {
+		t.Errorf("expected language tagged string, got %#v", props["ex:name"])
+	}
+	if value, ok := props["ex:born"].(DateTime); !ok || !value.Equal(born) {
+		t.Errorf("unexpected dateTime %#v", props["ex:born"])
+	}
+	if _, ok := props["ex:day"].(Date); !ok {
+		t.Errorf("unexpected date %#v", props["ex:day"])
+	}
+	if data, ok := props["ex:data"].(Binary); !ok || !bytes.Equal(data, []byte{1, 2, 3}) {
+		t.Errorf("unexpected binary %#v", props["ex:data"])
+	}
+}
+
+func TestJSONLDWriterWithConfiguredPrefixesAndPredicates(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	entity := NewEntity().SetID("ex:1")
+	entity.IsDeleted = true
+	entity.Recorded = 5
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	writer := NewJsonLDWriter().
+		WithPrefixes(map[string]string{"meta": "http://example.com/meta/"}).
+		WithMetadataPredicates("meta:deleted", "meta:recorded", "meta:internalId")
+
+	buffer := bytes.Buffer{}
+	if err := writer.Write(ec, &buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[{"@context":{"ex":"http://example.com/","meta":"http://example.com/meta/"}},` +
+		`{"@id":"ex:1","meta:deleted":true,"meta:recorded":{"@value":"5","@type":"http://www.w3.org/2001/XMLSchema#unsignedLong"}}]`
+	if buffer.String() != expected {
+		t.Errorf("unexpected output %s", buffer.String())
+	}
+
+	parsed, err := NewJsonLDParser(NewNamespaceContext()).
+		WithMetadataPredicates("meta:deleted", "meta:recorded", "meta:internalId").
+		LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Entities[0].IsDeleted || parsed.Entities[0].Recorded != 5 {
+		t.Errorf("unexpected metadata %v", parsed.Entities[0])
+	}
+}
+
+func TestJSONLDRoundTripWithCustomPrefixes(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	entity := NewEntity().SetID("ex:1")
+	entity.IsDeleted = true
+	entity.Recorded = 5
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+	continuation := NewContinuation()
+	continuation.Token = "1234"
+	ec.SetContinuationToken(continuation)
+
+	buffer := bytes.Buffer{}
+	if err := NewJsonLDWriter().WithPrefixes(map[string]string{"schema": "https://schema.org/"}).Write(ec, &buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	// the prefixes of the default metadata predicates and the continuation are declared
+	var document []map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	context := document[0]["@context"].(map[string]any)
+	if context["core"] != CoreNamespaceExpansion || context["rdf"] != RdfNamespaceExpansion || context["schema"] != "https://schema.org/" {
+		t.Errorf("unexpected context %v", context)
+	}
+
+	parsed, err := NewJsonLDParser(NewNamespaceContext()).LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Entities) != 1 || !parsed.Entities[0].IsDeleted || parsed.Entities[0].Recorded != 5 {
+		t.Errorf("unexpected entities %v", parsed.Entities)
+	}
+	if len(parsed.Entities[0].Properties) != 0 {
+		t.Errorf("expected metadata to not be read as properties, got %v", parsed.Entities[0].Properties)
+	}
+	if parsed.Continuation == nil || parsed.Continuation.Token != "1234" {
+		t.Errorf("expected continuation token 1234, got %v", parsed.Continuation)
+	}
+}
+
+func TestJSONLDWriterWithWellKnownPrefixes(t *testing.T) {
+	ec := NewEntityCollection(nil)
+	if err := ec.AddEntity(NewEntity().SetID("http://example.com/1")); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := NewJsonLDWriter().WithWellKnownPrefixes().Write(ec, &buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	var document []map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	context := document[0]["@context"].(map[string]any)
+	for prefix, expansion := range WellKnownPrefixes {
+		if context[prefix] != expansion {
+			t.Errorf("expected %s to be %s in the context, got %v", prefix, expansion, context[prefix])
+		}
+	}
+}
+
+func TestJSONLDWriterKeepsCollectionPrefixes(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("schema", "http://schema.org/")
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(NewEntity().SetID("schema:1").SetProperty("schema:name", "John")); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := NewJsonLDWriter().WithWellKnownPrefixes().Write(ec, &buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewJsonLDParser(NewNamespaceContext()).WithExpandURIs().LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Entities[0].ID != "http://schema.org/1" || parsed.Entities[0].Properties["http://schema.org/name"] != "John" {
+		t.Errorf("expected the schema prefix of the collection to be kept, got %v", parsed.Entities[0])
+	}
+}
+
+func TestJSONLDRoundTripWithCollectionBindingCorePrefix(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("core", "http://example.com/core/")
+	nsManager.StorePrefixExpansionMapping("rdf", "http://example.com/rdf/")
+	ec := NewEntityCollection(nsManager)
+	entity := NewEntity().SetID("core:1").SetProperty("rdf:type", "not a type")
+	entity.IsDeleted = true
+	entity.Recorded = 42
+	entity.InternalID = 7
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+	ec.SetContinuationToken(&Continuation{Token: "next"})
+
+	buffer := bytes.Buffer{}
+	if err := NewJsonLDWriter().Write(ec, &buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewJsonLDParser(NewNamespaceContext()).WithExpandURIs().LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Continuation == nil || parsed.Continuation.Token != "next" {
+		t.Errorf("expected the continuation to be read back, got %v", parsed.Continuation)
+	}
+	if len(parsed.Entities) != 1 {
+		t.Fatalf("expected one entity, got %v", parsed.Entities)
+	}
+	result := parsed.Entities[0]
+	if result.ID != "http://example.com/core/1" || !result.IsDeleted || result.Recorded != 42 || result.InternalID != 7 {
+		t.Errorf("unexpected entity %+v", result)
+	}
+	if result.Properties["http://example.com/rdf/type"] != "not a type" || len(result.Properties) != 1 {
+		t.Errorf("unexpected properties %v", result.Properties)
+	}
+}
+
+func TestParseJSONLDConcurrently(t *testing.T) {
+	parser := NewJsonLDParser(NewNamespaceContext()).WithExpandURIs().WithPrefixConflictPolicy(PrefixConflictRename)
+
+	// every document binds ex to its own expansion, so the prefix is renamed in all but one of them
+	wg := sync.WaitGroup{}
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				document := fmt.Sprintf(`{"@context":{"ex":"http://example.com/%d/"},"@id":"ex:%d","ex:name":"n"}`, w, i)
+				ec, err := parser.LoadEntityCollection(strings.NewReader(document))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				expected := fmt.Sprintf("http://example.com/%d/%d", w, i)
+				if ec.Entities[0].ID != expected || ec.Entities[0].Properties[fmt.Sprintf("http://example.com/%d/name", w)] != "n" {
+					t.Errorf("expected %s, got %+v", expected, ec.Entities[0])
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
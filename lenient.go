@@ -0,0 +1,374 @@
+package egdm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EntityErrorHandler is called by the EntityParser for an entity that can not be parsed. It gets the index of the
+// entity in the document, the raw JSON of the entity and the error, which is a *ParseError. If the handler returns nil
+// the entity is skipped and parsing continues with the next entity, otherwise parsing stops with the returned error.
+type EntityErrorHandler func(index int, raw json.RawMessage, err error) error
+
+// ParseSummary describes a document parsed by EntityParser.ParseWithSummary
+type ParseSummary struct {
+	// EntityCount is the number of entities that were parsed and emitted
+	EntityCount int
+	// Skipped holds the entities that were passed to the EntityErrorHandler and skipped
+	Skipped []SkippedEntity
+}
+
+// SkippedEntity is an entity that was skipped by an EntityParser with an EntityErrorHandler
+type SkippedEntity struct {
+	Index  int
+	Offset int64
+	Err    error
+}
+
+// parseRawEntities parses the document one raw entity at a time. With an entity error handler an entity that can not
+// be parsed is passed to the handler instead of failing the document, errors in the start of the document and in the
+// @context still stop parsing. Without a handler the first error stops parsing.
+func (esp *EntityParser) parseRawEntities(ctx context.Context, reader io.Reader, emitEntity func(*Entity) error, emitContinuation func(*Continuation), summary *ParseSummary) error {
+	if esp.limits.MaxBytes > 0 {
+		reader = newLimitedReader(reader, esp.limits.MaxBytes)
+	}
+	entities := &rawEntityReader{reader: bufio.NewReader(reader), lineDelimited: esp.lineDelimited}
+	err := entities.start()
+	if err != nil {
+		return err
+	}
+
+	var prefixRenames map[string]string
+	if esp.requireContext {
+		raw, offset, err := entities.next()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("no context found")
+			}
+			return &ParseError{Offset: entities.offset, EntityIndex: -1, Err: fmt.Errorf("%w: %w", ErrBadContext, err)}
+		}
+		state := esp.newRawParseState(raw, offset, nil)
+		err = esp.parseContext(state)
+		if err != nil {
+			return err
+		}
+		prefixRenames = state.prefixRenames
+		if _, err = state.decoder.Token(); err != io.EOF {
+			return state.errorf(ErrUnexpectedToken, "unexpected data after context")
+		}
+	}
+
+	for index := 0; ; index++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		raw, offset, err := entities.next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return entities.wrap(err, index)
+		}
+
+		state := esp.newRawParseState(raw, offset, prefixRenames)
+		state.entityIndex = index
+		e, err := esp.parseRawEntity(state)
+		if err != nil {
+			if esp.entityErrorHandler == nil {
+				return err
+			}
+			// skipped entities count against MaxEntities so that a document of bad entities is bounded too
+			if limitErr := esp.checkEntityLimit(state, summary); limitErr != nil {
+				return limitErr
+			}
+			summary.Skipped = append(summary.Skipped, SkippedEntity{Index: index, Offset: offset, Err: err})
+			if handlerErr := esp.entityErrorHandler(index, raw, err); handlerErr != nil {
+				return handlerErr
+			}
+			continue
+		}
+
+		err = esp.emit(state, e, emitEntity, emitContinuation, summary)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (esp *EntityParser) newRawParseState(raw []byte, offset int64, prefixRenames map[string]string) *parseState {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	state := newParseState(decoder, esp.limits)
+	state.offset = offset
+	state.prefixRenames = prefixRenames
+	return state
+}
+
+// parseRawEntity parses the raw JSON of one entity with a state from newRawParseState
+func (esp *EntityParser) parseRawEntity(state *parseState) (*Entity, error) {
+	t, err := state.token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '{' {
+		return nil, state.errorf(ErrUnexpectedToken, "expected { at start of entity")
+	}
+	e, err := esp.parseEntity(state)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = state.decoder.Token(); err != io.EOF {
+		return nil, state.errorf(ErrUnexpectedToken, "unexpected data after entity")
+	}
+	return e, nil
+}
+
+// rawEntityReader splits a document into the raw JSON of its objects without decoding them. Objects in an array are
+// found by matching braces outside strings. In line delimited documents each non-empty line is an object, so that a
+// malformed line does not affect the following lines.
+type rawEntityReader struct {
+	reader        *bufio.Reader
+	lineDelimited bool
+	// offset is the number of bytes read
+	offset int64
+	// hasValue is set once a value of the array is read, the following values must be preceded by a comma
+	hasValue bool
+}
+
+// start reads the [ at the start of an array document
+func (entities *rawEntityReader) start() error {
+	if entities.lineDelimited {
+		return nil
+	}
+	c, err := entities.skipSpace()
+	if err != nil && err != io.EOF {
+		return entities.wrap(err, -1)
+	}
+	if err != nil || c != '[' {
+		return &ParseError{Offset: entities.offset, EntityIndex: -1, Err: fmt.Errorf("%w: expected [ at start of document", ErrUnexpectedToken)}
+	}
+	entities.readByte()
+	return nil
+}
+
+// next returns the raw JSON of the next object and its offset, or io.EOF at the end of the document
+func (entities *rawEntityReader) next() ([]byte, int64, error) {
+	if entities.lineDelimited {
+		return entities.nextLine()
+	}
+
+	hasComma := false
+	for {
+		c, err := entities.skipSpace()
+		if err == io.EOF {
+			return nil, 0, &ParseError{Offset: entities.offset, EntityIndex: -1, Err: fmt.Errorf("%w: expected ] at end of document", ErrUnexpectedToken)}
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		switch c {
+		case ',':
+			if !entities.hasValue || hasComma {
+				return nil, 0, &ParseError{Offset: entities.offset, EntityIndex: -1, Err: fmt.Errorf("%w: expected value before ,", ErrUnexpectedToken)}
+			}
+			hasComma = true
+			entities.readByte()
+		case ']':
+			if hasComma {
+				return nil, 0, &ParseError{Offset: entities.offset, EntityIndex: -1, Err: fmt.Errorf("%w: expected value after ,", ErrUnexpectedToken)}
+			}
+			entities.readByte()
+			return nil, 0, io.EOF
+		default:
+			if entities.hasValue && !hasComma {
+				return nil, 0, &ParseError{Offset: entities.offset, EntityIndex: -1, Err: fmt.Errorf("%w: expected , between values", ErrUnexpectedToken)}
+			}
+			entities.hasValue = true
+			return entities.scanValue()
+		}
+	}
+}
+
+func (entities *rawEntityReader) nextLine() ([]byte, int64, error) {
+	for {
+		offset := entities.offset
+		line, err := entities.reader.ReadBytes('\n')
+		entities.offset += int64(len(line))
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 {
+			return trimmed, offset + int64(bytes.Index(line, trimmed)), nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+// scanValue reads a value of an array. Objects and arrays end at the matching bracket, other values end before the
+// next comma or closing bracket. A closing bracket that does not match the open bracket ends the value, the rest of the
+// malformed value is skipped up to the next entity.
+func (entities *rawEntityReader) scanValue() ([]byte, int64, error) {
+	offset := entities.offset
+	var raw []byte
+	var open []byte
+	inString := false
+	isEscaped := false
+	for {
+		c, err := entities.reader.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = &ParseError{Offset: entities.offset, EntityIndex: -1, Err: fmt.Errorf("%w: unexpected end of document", ErrUnexpectedToken)}
+			}
+			return nil, 0, err
+		}
+
+		if inString {
+			switch {
+			case isEscaped:
+				isEscaped = false
+			case c == '\\':
+				isEscaped = true
+			case c == '"':
+				inString = false
+			}
+		} else {
+			switch c {
+			case '"':
+				inString = true
+			case '{', '[':
+				open = append(open, c)
+			case '}', ']':
+				if len(open) == 0 && len(raw) > 0 {
+					// the end of the document array, it is read by next
+					entities.reader.UnreadByte()
+					return bytes.TrimSpace(raw), offset, nil
+				}
+				if len(open) > 0 {
+					if matchingBracket(open[len(open)-1]) != c {
+						entities.offset++
+						raw = append(raw, c)
+						if err = entities.skipMalformed(); err != nil && err != io.EOF {
+							return nil, 0, err
+						}
+						return raw, offset, nil
+					}
+					open = open[:len(open)-1]
+				}
+				// a closing brace without an opening brace is returned as a value of its own
+			case ',':
+				if len(open) == 0 {
+					entities.reader.UnreadByte()
+					return bytes.TrimSpace(raw), offset, nil
+				}
+			}
+		}
+
+		entities.offset++
+		raw = append(raw, c)
+		if len(open) == 0 && !inString && (c == '}' || c == ']') {
+			return raw, offset, nil
+		}
+	}
+}
+
+// skipMalformed skips the rest of a value with mismatched brackets, where the nesting is not known. It stops before a
+// comma that is followed by an object, which starts the next entity, or before a closing bracket that ends the
+// document.
+func (entities *rawEntityReader) skipMalformed() error {
+	inString := false
+	isEscaped := false
+	for {
+		peeked, err := entities.reader.Peek(1)
+		if err != nil {
+			return err
+		}
+
+		c := peeked[0]
+		if inString {
+			switch {
+			case isEscaped:
+				isEscaped = false
+			case c == '\\':
+				isEscaped = true
+			case c == '"':
+				inString = false
+			}
+		} else {
+			switch c {
+			case '"':
+				inString = true
+			case ',':
+				if next, err := entities.peekNonSpace(1); err == nil && next == '{' {
+					return nil
+				}
+			case ']':
+				if _, err := entities.peekNonSpace(1); err == io.EOF {
+					return nil
+				}
+			}
+		}
+		entities.readByte()
+	}
+}
+
+// peekNonSpace returns the first byte after white space from the given number of unread bytes on, without reading
+// it, or io.EOF if there is only white space left. White space that does not fit in the buffer of the reader is
+// returned as bufio.ErrBufferFull.
+func (entities *rawEntityReader) peekNonSpace(from int) (byte, error) {
+	for n := from + 1; ; n++ {
+		peeked, err := entities.reader.Peek(n)
+		if len(peeked) < n {
+			return 0, err
+		}
+		switch peeked[n-1] {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return peeked[n-1], nil
+		}
+	}
+}
+
+// matchingBracket returns the closing bracket of an open bracket
+func matchingBracket(open byte) byte {
+	if open == '{' {
+		return '}'
+	}
+	return ']'
+}
+
+// skipSpace skips white space and returns the next byte without reading it
+func (entities *rawEntityReader) skipSpace() (byte, error) {
+	for {
+		c, err := entities.reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			entities.offset++
+		default:
+			return c, entities.reader.UnreadByte()
+		}
+	}
+}
+
+// wrap returns a read error, such as a LimitExceededError for MaxBytes, as a ParseError at the offset of the reader
+func (entities *rawEntityReader) wrap(err error, index int) error {
+	var parseError *ParseError
+	if errors.As(err, &parseError) {
+		return err
+	}
+	return &ParseError{Offset: entities.offset, EntityIndex: index, Err: err}
+}
+
+func (entities *rawEntityReader) readByte() {
+	if _, err := entities.reader.ReadByte(); err == nil {
+		entities.offset++
+	}
+}
@@ -0,0 +1,214 @@
+package egdm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseWithEntityErrorHandler(t *testing.T) {
+	data := `[
+		{"id":"@context","namespaces":{"ex":"http://example.com/"}},
+		{"id":"ex:1","props":{"ex:name":"one"}},
+		{"id":"ex:2","props":{"bad:name":"two"}},
+		{"id":"ex:3","props":{"ex:name": tr}},
+		"not an entity",
+		{"id":"ex:4","props":{"ex:name":"a } in a string"}},
+		{"id":"ex:5","refs":{"ex:knows":["ex:1"]}},
+		{"id":"@continuation","token":"next"}
+	]`
+
+	var indexes []int
+	var raws []string
+	parser := NewEntityParser(NewNamespaceContext()).WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error {
+		var parseError *ParseError
+		if !errors.As(err, &parseError) || parseError.EntityIndex != index {
+			t.Errorf("expected ParseError for entity %d, got %v", index, err)
+		}
+		indexes = append(indexes, index)
+		raws = append(raws, string(raw))
+		return nil
+	})
+	ec := NewEntityCollection(parser.GetNamespaceManager())
+	summary, err := parser.ParseWithSummary(context.Background(), strings.NewReader(data), ec.AddEntity, ec.SetContinuationToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ec.Entities) != 3 || ec.Entities[0].ID != "ex:1" || ec.Entities[1].ID != "ex:4" || ec.Entities[2].ID != "ex:5" {
+		t.Errorf("unexpected entities %v", ec.Entities)
+	}
+	if ec.Continuation == nil || ec.Continuation.Token != "next" {
+		t.Errorf("expected continuation to be parsed")
+	}
+	if len(indexes) != 3 || indexes[0] != 1 || indexes[1] != 2 || indexes[2] != 3 {
+		t.Errorf("unexpected skipped entities %v", indexes)
+	}
+	if raws[0] != `{"id":"ex:2","props":{"bad:name":"two"}}` || raws[2] != `"not an entity"` {
+		t.Errorf("unexpected raw entities %q", raws)
+	}
+
+	if summary.EntityCount != 3 || len(summary.Skipped) != 3 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if !errors.Is(summary.Skipped[0].Err, ErrMissingPrefix) || summary.Skipped[0].Offset != int64(strings.Index(data, `{"id":"ex:2"`)) {
+		t.Errorf("unexpected skipped entity %+v", summary.Skipped[0])
+	}
+}
+
+func TestParseWithEntityErrorHandlerStops(t *testing.T) {
+	data := `[
+		{"id":"@context","namespaces":{"ex":"http://example.com/"}},
+		{"id":"ex:1","props":{"bad:name":"one"}},
+		{"id":"ex:2"}
+	]`
+
+	stop := errors.New("stop")
+	count := 0
+	err := NewEntityParser(NewNamespaceContext()).
+		WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error { return stop }).
+		Parse(strings.NewReader(data), func(e *Entity) error { count++; return nil }, nil)
+	if err != stop || count != 0 {
+		t.Errorf("expected handler error to stop parsing, got %v after %d entities", err, count)
+	}
+
+	// errors in the context are not passed to the handler
+	_, err = NewEntityParser(NewNamespaceContext()).
+		WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error { return nil }).
+		LoadEntityCollection(strings.NewReader(`[{"id":"@context","namespaces":{"ex":"http://example.com"}}, {"id":"ex:1"}]`))
+	if !errors.Is(err, ErrBadContext) {
+		t.Errorf("expected bad context error, got %v", err)
+	}
+
+	_, err = NewEntityParser(NewNamespaceContext()).
+		WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error { return nil }).
+		LoadEntityCollection(strings.NewReader(`[{"id":"@context","namespaces":{"ex":"http://example.com/"}}, {"id":"ex:1"`))
+	if !errors.Is(err, ErrUnexpectedToken) {
+		t.Errorf("expected truncated document to fail, got %v", err)
+	}
+}
+
+func TestParseWithEntityErrorHandlerMismatchedBrackets(t *testing.T) {
+	documents := map[string]string{
+		"array closed by brace":  `[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1"},{"id":"ex:3","props":{"ex:a":[1,2}},{"id":"ex:4"}]`,
+		"object closed by array": `[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1"},{"id":"ex:3","props":{"ex:a":"]"]}, {"id":"ex:4"}]`,
+		"last entity":            `[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1"},{"id":"ex:4"},{"id":"ex:3","props":[}} ]`,
+	}
+
+	for name, document := range documents {
+		var raws []string
+		ec, err := NewEntityParser(NewNamespaceContext()).
+			WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error {
+				var parseError *ParseError
+				if !errors.As(err, &parseError) {
+					t.Errorf("%s: expected ParseError, got %v", name, err)
+				}
+				raws = append(raws, string(raw))
+				return nil
+			}).
+			LoadEntityCollection(strings.NewReader(document))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(ec.Entities) != 2 || !ec.HasEntity("ex:1") || !ec.HasEntity("ex:4") {
+			t.Errorf("%s: unexpected entities %v", name, ec.Entities)
+		}
+		if len(raws) != 1 || !strings.HasPrefix(raws[0], `{"id":"ex:3"`) {
+			t.Errorf("%s: unexpected skipped entities %q", name, raws)
+		}
+	}
+
+	// a malformed entity at the end of a truncated document still fails the document
+	_, err := NewEntityParser(NewNamespaceContext()).WithNoContext().
+		WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error { return nil }).
+		LoadEntityCollection(strings.NewReader(`[{"id":"http://example.com/1","props":[}`))
+	if !errors.Is(err, ErrUnexpectedToken) {
+		t.Errorf("expected truncated document to fail, got %v", err)
+	}
+}
+
+func TestParseWithEntityErrorHandlerRequiresCommas(t *testing.T) {
+	documents := map[string]string{
+		"missing comma":  `[{"id":"http://example.com/1"} {"id":"http://example.com/2"}]`,
+		"trailing comma": `[{"id":"http://example.com/1"},]`,
+		"leading comma":  `[,{"id":"http://example.com/1"}]`,
+		"double comma":   `[{"id":"http://example.com/1"},,{"id":"http://example.com/2"}]`,
+		"stray brace":    `[{"id":"http://example.com/1"}}, {"id":"http://example.com/2"}]`,
+	}
+
+	for name, document := range documents {
+		handled := 0
+		_, err := NewEntityParser(NewNamespaceContext()).WithNoContext().
+			WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error { handled++; return nil }).
+			LoadEntityCollection(strings.NewReader(document))
+		if !errors.Is(err, ErrUnexpectedToken) {
+			t.Errorf("%s: expected unexpected token error, got %v", name, err)
+		}
+		if handled != 0 {
+			t.Errorf("%s: expected the document error not to be passed to the handler", name)
+		}
+	}
+}
+
+func TestParseNDJSONWithEntityErrorHandler(t *testing.T) {
+	data := `{"id":"@context","namespaces":{"ex":"http://example.com/"}}
+{"id":"ex:1","props":{"ex:name":"one"}}
+{"id":"ex:2","props":{"ex:name":"unterminated}
+
+{"id":"ex:3","props":{"ex:name":"three"}}
+`
+
+	var skipped []int
+	parser := NewEntityParser(NewNamespaceContext()).WithNDJSON().
+		WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error {
+			skipped = append(skipped, index)
+			return nil
+		})
+	ec := NewEntityCollection(parser.GetNamespaceManager())
+	summary, err := parser.ParseWithSummary(context.Background(), strings.NewReader(data), ec.AddEntity, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ec.Entities) != 2 || ec.Entities[1].ID != "ex:3" || len(skipped) != 1 || skipped[0] != 1 {
+		t.Errorf("unexpected result %v %v", ec.Entities, skipped)
+	}
+	if summary.EntityCount != 2 || len(summary.Skipped) != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func TestParseWithSummaryConcurrently(t *testing.T) {
+	parser := NewEntityParser(NewNamespaceContext()).
+		WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error { return nil })
+
+	// every worker parses documents with its own number of good and bad entities
+	wg := sync.WaitGroup{}
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			entities := []string{`{"id":"@context","namespaces":{"ex":"http://example.com/"}}`}
+			for i := 0; i < w; i++ {
+				entities = append(entities, fmt.Sprintf(`{"id":"ex:%d"}`, i), `{"id":"bad:1"}`)
+			}
+			document := "[" + strings.Join(entities, ",") + "]"
+			for i := 0; i < 20; i++ {
+				summary, err := parser.ParseWithSummary(context.Background(), strings.NewReader(document), func(e *Entity) error { return nil }, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if summary.EntityCount != w || len(summary.Skipped) != w {
+					t.Errorf("expected %d parsed and skipped entities, got %+v", w, summary)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
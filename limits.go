@@ -0,0 +1,83 @@
+package egdm
+
+import (
+	"fmt"
+	"io"
+)
+
+// ParserLimits bounds the resources an EntityParser uses for a document. A zero value means no limit.
+type ParserLimits struct {
+	// MaxDepth is the maximum nesting of embedded entities and arrays, the entity itself is at depth 1
+	MaxDepth int
+	// MaxEntities is the maximum number of entities in the document. The @context and the @continuation are not
+	// counted, entities skipped by the entity error handler are.
+	MaxEntities int
+	// MaxProperties is the maximum number of keys in the props, and in the refs, of an entity
+	MaxProperties int
+	// MaxArrayLength is the maximum number of values in an array
+	MaxArrayLength int
+	// MaxStringLength is the maximum length in bytes of a string or number. The length is checked after the JSON
+	// decoder has read and decoded the whole value, so an oversized value is still read into memory. Use MaxBytes to
+	// bound the memory used for a document.
+	MaxStringLength int
+	// MaxBytes is the maximum size of the document in bytes
+	MaxBytes int64
+}
+
+// LimitExceededError is wrapped by the ParseError returned when a document exceeds one of the ParserLimits
+type LimitExceededError struct {
+	// Limit is the name of the field of ParserLimits that was exceeded
+	Limit string
+	Max   int64
+}
+
+func (limitError *LimitExceededError) Error() string {
+	return fmt.Sprintf("parser limit %s of %d exceeded", limitError.Limit, limitError.Max)
+}
+
+// limitedReader returns a LimitExceededError when more than max bytes are read from the reader
+type limitedReader struct {
+	reader    io.Reader
+	max       int64
+	remaining int64
+}
+
+func newLimitedReader(reader io.Reader, max int64) *limitedReader {
+	return &limitedReader{reader: reader, max: max, remaining: max}
+}
+
+func (limited *limitedReader) Read(p []byte) (int, error) {
+	if limited.remaining <= 0 {
+		// the document may end exactly at the limit
+		var probe [1]byte
+		n, err := limited.reader.Read(probe[:])
+		if n > 0 {
+			return 0, &LimitExceededError{Limit: "MaxBytes", Max: limited.max}
+		}
+		return 0, err
+	}
+	if int64(len(p)) > limited.remaining {
+		p = p[:limited.remaining]
+	}
+	n, err := limited.reader.Read(p)
+	limited.remaining -= int64(n)
+	return n, err
+}
+
+// checkLimit returns a ParseError wrapping a LimitExceededError if the value is above the max of the named limit
+func (state *parseState) checkLimit(limit string, max int, value int) error {
+	if max > 0 && value > max {
+		return state.wrap(&LimitExceededError{Limit: limit, Max: int64(max)})
+	}
+	return nil
+}
+
+// enter increases the nesting depth for an embedded entity or array
+func (state *parseState) enter() error {
+	state.depth++
+	return state.checkLimit("MaxDepth", state.limits.MaxDepth, state.depth)
+}
+
+func (state *parseState) leave() {
+	state.depth--
+}
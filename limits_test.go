@@ -0,0 +1,94 @@
+package egdm
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParserLimits(t *testing.T) {
+	context := `{"id":"@context","namespaces":{"ex":"http://example.com/"}}`
+	deep := `{"id":"ex:1","props":{"ex:a":{"props":{"ex:b":[[{"props":{"ex:c":1}}]]}}}}`
+
+	for name, test := range map[string]struct {
+		limits ParserLimits
+		data   string
+		limit  string
+	}{
+		"depth":                    {ParserLimits{MaxDepth: 4}, `[` + context + `,` + deep + `]`, "MaxDepth"},
+		"entities":                 {ParserLimits{MaxEntities: 1}, `[` + context + `,{"id":"ex:1"},{"id":"ex:2"}]`, "MaxEntities"},
+		"properties":               {ParserLimits{MaxProperties: 1}, `[` + context + `,{"id":"ex:1","props":{"ex:a":1,"ex:b":2}}]`, "MaxProperties"},
+		"references":               {ParserLimits{MaxProperties: 1}, `[` + context + `,{"id":"ex:1","refs":{"ex:a":"ex:2","ex:b":"ex:3"}}]`, "MaxProperties"},
+		"array":                    {ParserLimits{MaxArrayLength: 2}, `[` + context + `,{"id":"ex:1","props":{"ex:a":[1,2,3]}}]`, "MaxArrayLength"},
+		"ref array":                {ParserLimits{MaxArrayLength: 2}, `[` + context + `,{"id":"ex:1","refs":{"ex:a":["ex:1","ex:2","ex:3"]}}]`, "MaxArrayLength"},
+		"string":                   {ParserLimits{MaxStringLength: 5}, `[` + context + `,{"id":"ex:1","props":{"ex:a":"too long"}}]`, "MaxStringLength"},
+		"number":                   {ParserLimits{MaxStringLength: 5}, `[` + context + `,{"id":"ex:1","props":{"ex:a":1234567}}]`, "MaxStringLength"},
+		"bytes":                    {ParserLimits{MaxBytes: 100}, `[` + context + `,{"id":"ex:1"},{"id":"ex:2"},{"id":"ex:3"}]`, "MaxBytes"},
+		"lenient bytes":            {ParserLimits{MaxBytes: 100}, `[` + context + `,{"id":"ex:1"},{"id":"ex:2"},{"id":"ex:3"}]`, "MaxBytes"},
+		"lenient start":            {ParserLimits{MaxBytes: 2}, `    [` + context + `]`, "MaxBytes"},
+		"lenient skipped entities": {ParserLimits{MaxEntities: 1}, `[` + context + `,{"id":"bad:1"},{"id":"ex:2"}]`, "MaxEntities"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parser := NewEntityParser(NewNamespaceContext()).WithLimits(test.limits)
+			if strings.HasPrefix(name, "lenient") {
+				parser.WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error { return nil })
+			}
+			_, err := parser.LoadEntityCollection(strings.NewReader(test.data))
+			var limitError *LimitExceededError
+			if !errors.As(err, &limitError) {
+				t.Fatalf("expected LimitExceededError, got %v", err)
+			}
+			if limitError.Limit != test.limit {
+				t.Errorf("expected %s to be exceeded, got %s", test.limit, limitError.Limit)
+			}
+			var parseError *ParseError
+			if !errors.As(err, &parseError) || parseError.Offset == 0 {
+				t.Errorf("expected ParseError with offset, got %#v", err)
+			}
+		})
+	}
+}
+
+func TestParserLimitsAllowDocumentsWithinLimits(t *testing.T) {
+	data := `[{"id":"@context","namespaces":{"ex":"http://example.com/"}},` +
+		`{"id":"ex:1","props":{"ex:a":{"props":{"ex:b":[1,2]}}},"refs":{"ex:c":["ex:2","ex:3"]}}]`
+	limits := ParserLimits{MaxDepth: 3, MaxEntities: 1, MaxProperties: 1, MaxArrayLength: 2, MaxStringLength: 60, MaxBytes: int64(len(data))}
+
+	ec, err := NewEntityParser(NewNamespaceContext()).WithLimits(limits).LoadEntityCollection(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ec.Entities) != 1 {
+		t.Errorf("expected one entity, got %d", len(ec.Entities))
+	}
+
+	// the continuation is not an entity
+	data = `[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1"},{"id":"@continuation","token":"next"}]`
+	for _, lenient := range []bool{false, true} {
+		parser := NewEntityParser(NewNamespaceContext()).WithLimits(ParserLimits{MaxEntities: 1})
+		if lenient {
+			parser.WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error { return nil })
+		}
+		ec, err = parser.LoadEntityCollection(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ec.Entities) != 1 || ec.Continuation == nil || ec.Continuation.Token != "next" {
+			t.Errorf("expected one entity and a continuation, got %v %v", ec.Entities, ec.Continuation)
+		}
+	}
+
+	// in lenient mode an entity that exceeds a limit is skipped
+	data = `[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1","props":{"ex:a":[1,2,3]}},{"id":"ex:2"}]`
+	skipped := 0
+	ec, err = NewEntityParser(NewNamespaceContext()).WithLimits(ParserLimits{MaxArrayLength: 2}).
+		WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error { skipped++; return nil }).
+		LoadEntityCollection(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 1 || len(ec.Entities) != 1 {
+		t.Errorf("expected the entity over the limit to be skipped, got %d skipped", skipped)
+	}
+}
@@ -0,0 +1,115 @@
+package egdm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	xsdDateTime     = XsdNamespaceExpansion + "dateTime"
+	xsdDate         = XsdNamespaceExpansion + "date"
+	xsdDecimal      = XsdNamespaceExpansion + "decimal"
+	xsdBase64Binary = XsdNamespaceExpansion + "base64Binary"
+	rdfLangString   = RdfNamespaceExpansion + "langString"
+
+	xsdDateLayout = "2006-01-02"
+)
+
+// DateTime is a property value for an xsd:dateTime. In the entity graph JSON format it is written as
+// {"@value": "2006-01-02T15:04:05Z", "@type": "http://www.w3.org/2001/XMLSchema#dateTime"}.
+type DateTime struct {
+	time.Time
+}
+
+// Date is a property value for an xsd:date. In the entity graph JSON format it is written as
+// {"@value": "2006-01-02", "@type": "http://www.w3.org/2001/XMLSchema#date"}.
+type Date struct {
+	time.Time
+}
+
+// Decimal is a property value for an xsd:decimal. The value is kept in its lexical form so that no precision is
+// lost. In the entity graph JSON format it is written as {"@value": "1.10", "@type": "http://www.w3.org/2001/XMLSchema#decimal"}.
+type Decimal string
+
+// LangString is a language tagged string property value. In the entity graph JSON format it is written as
+// {"@value": "hello", "@language": "en"}.
+type LangString struct {
+	Value    string
+	Language string
+}
+
+// Binary is a property value for binary data. In the entity graph JSON format it is written as the base64 encoded
+// data in {"@value": "aGVsbG8=", "@type": "http://www.w3.org/2001/XMLSchema#base64Binary"}.
+type Binary []byte
+
+func (dateTime DateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(JsonLdValue{Value: dateTime.Format(time.RFC3339Nano), Type: xsdDateTime})
+}
+
+func (date Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(JsonLdValue{Value: date.Format(xsdDateLayout), Type: xsdDate})
+}
+
+func (decimal Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(JsonLdValue{Value: string(decimal), Type: xsdDecimal})
+}
+
+// Rat returns the value of the decimal as a big.Rat, ok is false if the decimal is not a valid xsd:decimal
+func (decimal Decimal) Rat() (*big.Rat, bool) {
+	if !isDecimalLexical(string(decimal)) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(string(decimal))
+}
+
+func (langString LangString) MarshalJSON() ([]byte, error) {
+	return json.Marshal(JsonLdValue{Value: langString.Value, Language: langString.Language})
+}
+
+func (binary Binary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(JsonLdValue{Value: base64.StdEncoding.EncodeToString(binary), Type: xsdBase64Binary})
+}
+
+// parseDate parses an xsd:date, with or without a timezone
+func parseDate(lexical string) (time.Time, error) {
+	if date, err := time.Parse(xsdDateLayout, lexical); err == nil {
+		return date, nil
+	}
+	return time.Parse(xsdDateLayout+"Z07:00", lexical)
+}
+
+// isDecimalLexical returns true if the value is in the xsd:decimal lexical space, an optionally signed sequence of
+// digits with an optional fraction
+func isDecimalLexical(value string) bool {
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		value = value[1:]
+	}
+	if value == "" || value == "." {
+		return false
+	}
+	seenPoint := false
+	for _, r := range value {
+		switch {
+		case r == '.' && !seenPoint:
+			seenPoint = true
+		case r < '0' || r > '9':
+			return false
+		}
+	}
+	return true
+}
+
+// isDoubleLexical returns true if the value is a number written with digits, signs, a decimal point and an exponent
+// that strconv.ParseFloat can read. Values that are out of the range of float64 are numbers too.
+func isDoubleLexical(value string) bool {
+	if strings.IndexFunc(value, func(r rune) bool { return !strings.ContainsRune("+-.eE", r) && (r < '0' || r > '9') }) != -1 {
+		return false
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil || errors.Is(err, strconv.ErrRange)
+}
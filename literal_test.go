@@ -0,0 +1,156 @@
+package egdm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypedLiteralsRoundTrip(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	created := time.Date(2023, 10, 16, 12, 30, 15, 500000000, time.UTC)
+	entity := NewEntity().SetID("ex:1")
+	entity.SetProperty("ex:created", DateTime{created})
+	entity.SetProperty("ex:birthday", Date{time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)})
+	entity.SetProperty("ex:price", Decimal("10.10"))
+	entity.SetProperty("ex:name", []any{LangString{Value: "Jean", Language: "fr"}, LangString{Value: "John", Language: "en"}})
+	entity.SetProperty("ex:data", Binary("hello"))
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteEntityGraphJSON(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), `"ex:created":{"@value":"2023-10-16T12:30:15.5Z","@type":"http://www.w3.org/2001/XMLSchema#dateTime"}`) {
+		t.Errorf("unexpected dateTime encoding: %s", buffer.String())
+	}
+	if !strings.Contains(buffer.String(), `{"@value":"Jean","@language":"fr"}`) {
+		t.Errorf("unexpected language string encoding: %s", buffer.String())
+	}
+
+	result, err := NewEntityParser(NewNamespaceContext()).WithExpandURIs().LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	parsed := result.Entities[0]
+
+	createdValue, err := parsed.GetFirstDateTimePropertyValue("http://example.com/created")
+	if err != nil || !createdValue.Equal(created) {
+		t.Errorf("unexpected created value %v %v", createdValue, err)
+	}
+	if parsed.Properties["http://example.com/birthday"] != (Date{time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)}) {
+		t.Errorf("unexpected birthday %#v", parsed.Properties["http://example.com/birthday"])
+	}
+	price, err := parsed.GetFirstDecimalPropertyValue("http://example.com/price")
+	if err != nil || price != "10.10" {
+		t.Errorf("unexpected price %v %v", price, err)
+	}
+	names, err := parsed.GetLangStringPropertyValues("http://example.com/name")
+	if err != nil || len(names) != 2 || names[1] != (LangString{Value: "John", Language: "en"}) {
+		t.Errorf("unexpected names %v %v", names, err)
+	}
+	data, err := parsed.GetFirstBinaryPropertyValue("http://example.com/data")
+	if err != nil || string(data) != "hello" {
+		t.Errorf("unexpected data %v %v", data, err)
+	}
+}
+
+func TestParseValueObjects(t *testing.T) {
+	byteReader := bytes.NewReader([]byte(`
+		[ {"id":"@context","namespaces":{"ex":"http://example.com/","xsd":"http://www.w3.org/2001/XMLSchema#"}},
+		  {"id":"ex:1","props":{
+			"ex:count":{"@value":"5","@type":"xsd:integer"},
+			"ex:plain":{"@value":12},
+			"ex:unit":{"@value":"kg","@type":"ex:Unit"},
+			"ex:bad":{"@value":"yesterday","@type":"xsd:dateTime"}
+		  }}
+		]`))
+
+	ec, err := NewEntityParser(NewNamespaceContext()).LoadEntityCollection(byteReader)
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	props := ec.Entities[0].Properties
+	if props["ex:count"] != 5 {
+		t.Errorf("expected int 5, got %T %v", props["ex:count"], props["ex:count"])
+	}
+	if props["ex:plain"] != float64(12) {
+		t.Errorf("expected float64 12, got %T %v", props["ex:plain"], props["ex:plain"])
+	}
+	// values of unknown datatypes and invalid values are kept with their datatype
+	if props["ex:unit"] != (JsonLdValue{Value: "kg", Type: "ex:Unit"}) {
+		t.Errorf("unexpected unit %#v", props["ex:unit"])
+	}
+	if props["ex:bad"] != (JsonLdValue{Value: "yesterday", Type: "xsd:dateTime"}) {
+		t.Errorf("unexpected bad value %#v", props["ex:bad"])
+	}
+}
+
+func TestParseValueObjectWithEntityKeys(t *testing.T) {
+	byteReader := bytes.NewReader([]byte(`
+		[ {"id":"@context","namespaces":{"ex":"http://example.com/"}},
+		  {"id":"ex:1","props":{"ex:value":{"id":"ex:2","@value":"x"}}}
+		]`))
+
+	_, err := NewEntityParser(NewNamespaceContext()).LoadEntityCollection(byteReader)
+	if err == nil {
+		t.Errorf("expected error for value object with an id")
+	}
+}
+
+func TestWriteTypedLiteralsAsRDF(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	entity := NewEntity().SetID("ex:1")
+	entity.SetProperty("ex:created", DateTime{time.Date(2023, 10, 16, 12, 30, 15, 0, time.UTC)})
+	entity.SetProperty("ex:price", Decimal("10.10"))
+	entity.SetProperty("ex:name", LangString{Value: "Jean", Language: "fr"})
+	entity.SetProperty("ex:data", Binary("hello"))
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteNTriples(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<http://example.com/1> <http://example.com/created> "2023-10-16T12:30:15Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+<http://example.com/1> <http://example.com/data> "aGVsbG8="^^<http://www.w3.org/2001/XMLSchema#base64Binary> .
+<http://example.com/1> <http://example.com/name> "Jean"@fr .
+<http://example.com/1> <http://example.com/price> "10.10"^^<http://www.w3.org/2001/XMLSchema#decimal> .
+`
+	if buffer.String() != expected {
+		t.Errorf("unexpected n-triples output:\n%s", buffer.String())
+	}
+
+	buffer.Reset()
+	if err := ec.WriteTurtle(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), `ex:name "Jean"@fr ;`) || !strings.Contains(buffer.String(), `ex:price 10.10 .`) {
+		t.Errorf("unexpected turtle output:\n%s", buffer.String())
+	}
+
+	// the rdf parser reads the datatypes back into the typed values
+	result, err := NewRDFParser(NewNamespaceContext()).LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := result.Entities[0].GetFirstBinaryPropertyValue("http://example.com/data")
+	if err != nil || string(data) != "hello" {
+		t.Errorf("unexpected data %v %v", data, err)
+	}
+	if _, err := result.Entities[0].GetFirstDateTimePropertyValue("http://example.com/created"); err != nil {
+		t.Errorf("expected dateTime value: %v", err)
+	}
+}
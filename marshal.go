@@ -0,0 +1,576 @@
+package egdm
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// fieldMapping is the parsed egdm struct tag of a field. The tag is one of
+//
+//	egdm:"id"                     the field holds the entity id
+//	egdm:"prop=ex:name"           the field holds the values of the ex:name property
+//	egdm:"ref=ex:worksFor"        the field holds the values of the ex:worksFor reference
+//	egdm:"-"                      the field is ignored
+//
+// and prop and ref mappings can be followed by the omitempty option, e.g. egdm:"prop=ex:name,omitempty".
+// Fields without a tag are ignored.
+type fieldMapping struct {
+	kind      string
+	key       string
+	omitEmpty bool
+}
+
+const (
+	fieldMappingID   = "id"
+	fieldMappingProp = "prop"
+	fieldMappingRef  = "ref"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	dateTimeType = reflect.TypeOf(DateTime{})
+	dateType     = reflect.TypeOf(Date{})
+	entityType   = reflect.TypeOf(Entity{})
+)
+
+// Marshal creates an entity from a struct or a pointer to a struct using the egdm tags of its fields. Nested structs
+// become embedded entities and time.Time values become DateTime values. Entity fields are copied, so that changing the
+// maps of the marshalled entity does not change the struct. The keys in the tags, the id and the reference values are
+// kept as they are written, as the EntityParser keeps them, so that Unmarshal finds the keys again. The namespace
+// manager is used to check that every CURIE has a prefix with an expansion. A value that contains itself, through a
+// pointer or a slice, can not be marshalled and returns an error.
+func Marshal(v any, ns NamespaceManager) (*Entity, error) {
+	state := &marshalState{ns: ns, visiting: make(map[visitKey]bool)}
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil, errors.New("unable to marshal nil value")
+		}
+		if _, err := state.enter(value); err != nil {
+			return nil, err
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unable to marshal %T, value must be a struct", v)
+	}
+	return state.marshalStruct(value)
+}
+
+// marshalState holds the namespace manager of a Marshal call and the pointers and slices that are being marshalled,
+// so that a value that contains itself is found instead of being marshalled without end
+type marshalState struct {
+	ns       NamespaceManager
+	visiting map[visitKey]bool
+}
+
+// visitKey identifies a pointer or slice by its address, type and, for slices, length, as a slice can share its
+// address with a shorter slice of the same array
+type visitKey struct {
+	address   uintptr
+	valueType reflect.Type
+	sliceLen  int
+}
+
+// enter marks the pointer or slice as being marshalled and returns a function that removes the mark again. It returns
+// an error if the value is already being marshalled.
+func (state *marshalState) enter(value reflect.Value) (func(), error) {
+	key := visitKey{address: value.Pointer(), valueType: value.Type()}
+	if value.Kind() == reflect.Slice {
+		key.sliceLen = value.Len()
+	}
+	if state.visiting[key] {
+		return nil, fmt.Errorf("unable to marshal cyclic value of type %s", value.Type())
+	}
+	state.visiting[key] = true
+	return func() { delete(state.visiting, key) }, nil
+}
+
+func (state *marshalState) marshalStruct(value reflect.Value) (*Entity, error) {
+	ns := state.ns
+	entity := NewEntity()
+	structType := value.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		mapping, ok, err := parseFieldMapping(field)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if mapping.omitEmpty && isEmptyValue(fieldValue) {
+			continue
+		}
+
+		switch mapping.kind {
+		case fieldMappingID:
+			id, ok := indirect(fieldValue)
+			if !ok {
+				continue
+			}
+			if id.Kind() != reflect.String {
+				return nil, fmt.Errorf("id field %s must be a string", field.Name)
+			}
+			if id.String() == "" {
+				continue
+			}
+			entity.ID, err = checkIdentifier(id.String(), ns)
+			if err != nil {
+				return nil, err
+			}
+		case fieldMappingRef:
+			key, err := checkIdentifier(mapping.key, ns)
+			if err != nil {
+				return nil, err
+			}
+			refs, err := marshalReferences(fieldValue, ns)
+			if err != nil {
+				return nil, fmt.Errorf("unable to marshal field %s: %w", field.Name, err)
+			}
+			if refs != nil {
+				entity.References[key] = refs
+			}
+		case fieldMappingProp:
+			key, err := checkIdentifier(mapping.key, ns)
+			if err != nil {
+				return nil, err
+			}
+			prop, err := state.marshalValue(fieldValue)
+			if err != nil {
+				return nil, fmt.Errorf("unable to marshal field %s: %w", field.Name, err)
+			}
+			if prop != nil {
+				entity.Properties[key] = prop
+			}
+		}
+	}
+
+	return entity, nil
+}
+
+// marshalReferences returns a string or []string of checked identifiers for a string or slice of strings field
+func marshalReferences(value reflect.Value, ns NamespaceManager) (any, error) {
+	value, ok := indirect(value)
+	if !ok {
+		return nil, nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		if value.String() == "" {
+			return nil, nil
+		}
+		return checkIdentifier(value.String(), ns)
+	case reflect.Slice, reflect.Array:
+		refs := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			ref, ok := indirect(value.Index(i))
+			if !ok {
+				continue
+			}
+			if ref.Kind() != reflect.String {
+				return nil, fmt.Errorf("unsupported reference type %s", ref.Type())
+			}
+			id, err := checkIdentifier(ref.String(), ns)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, id)
+		}
+		return refs, nil
+	}
+	return nil, fmt.Errorf("unsupported reference type %s", value.Type())
+}
+
+// marshalValue returns the property value for a field value
+func (state *marshalState) marshalValue(value reflect.Value) (any, error) {
+	switch value.Kind() {
+	case reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return state.marshalValue(value.Elem())
+	case reflect.Pointer:
+		if value.IsNil() {
+			return nil, nil
+		}
+		leave, err := state.enter(value)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return state.marshalValue(value.Elem())
+	}
+
+	switch value.Type() {
+	case timeType:
+		return DateTime{value.Interface().(time.Time)}, nil
+	case reflect.TypeOf(Binary{}), reflect.TypeOf(Decimal("")), reflect.TypeOf(LangString{}), dateTimeType, dateType:
+		return value.Interface(), nil
+	case entityType:
+		entity := value.Interface().(Entity)
+		entity.References = maps.Clone(entity.References)
+		entity.Properties = maps.Clone(entity.Properties)
+		entity.Extensions = maps.Clone(entity.Extensions)
+		return &entity, nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return value.Bool(), nil
+	case reflect.Int:
+		return int(value.Int()), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	case reflect.Struct:
+		return state.marshalStruct(value)
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			// arrays of an unaddressable struct value have no Bytes, so the bytes are copied
+			bytes := make([]byte, value.Len())
+			reflect.Copy(reflect.ValueOf(bytes), value)
+			return Binary(bytes), nil
+		}
+		if value.Kind() == reflect.Slice && value.Len() > 0 {
+			leave, err := state.enter(value)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		values := make([]any, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			val, err := state.marshalValue(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			if val != nil {
+				values = append(values, val)
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unsupported property type %s", value.Type())
+}
+
+// Unmarshal sets the fields of the struct pointed to by v from the entity using the egdm tags of the fields. The keys
+// in the tags must match the keys of the entity as they are, as they do for an entity created by Marshal. Use
+// UnmarshalWithNamespaceManager to match keys in either prefixed or expanded form.
+func Unmarshal(e *Entity, v any) error {
+	return UnmarshalWithNamespaceManager(e, v, nil)
+}
+
+// UnmarshalWithNamespaceManager sets the fields of the struct pointed to by v from the entity using the egdm tags of
+// the fields. The keys in the tags and the keys of the entity are compared as full URIs expanded with the namespace manager.
+func UnmarshalWithNamespaceManager(e *Entity, v any, ns NamespaceManager) error {
+	if e == nil {
+		return errors.New("unable to unmarshal nil entity")
+	}
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("unable to unmarshal into %T, value must be a pointer to a struct", v)
+	}
+	return unmarshalStruct(e, value.Elem(), ns)
+}
+
+func unmarshalStruct(e *Entity, value reflect.Value, ns NamespaceManager) error {
+	structType := value.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		mapping, ok, err := parseFieldMapping(field)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		switch mapping.kind {
+		case fieldMappingID:
+			if e.ID == "" {
+				continue
+			}
+			err = unmarshalValue(e.ID, fieldValue, ns)
+		case fieldMappingRef:
+			ref, found := lookupKey(e.References, mapping.key, ns)
+			if !found {
+				continue
+			}
+			var refs []string
+			refs, err = referenceValues(ref)
+			if err == nil {
+				err = unmarshalReferences(refs, fieldValue)
+			}
+		case fieldMappingProp:
+			prop, found := lookupKey(e.Properties, mapping.key, ns)
+			if !found || prop == nil {
+				continue
+			}
+			err = unmarshalValue(prop, fieldValue, ns)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal field %s: %w", field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func unmarshalReferences(refs []string, value reflect.Value) error {
+	value = allocate(value)
+
+	switch value.Kind() {
+	case reflect.String:
+		if len(refs) > 0 {
+			value.SetString(refs[0])
+		}
+		return nil
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported reference type %s", value.Type())
+		}
+		slice := reflect.MakeSlice(value.Type(), len(refs), len(refs))
+		for i, ref := range refs {
+			slice.Index(i).SetString(ref)
+		}
+		value.Set(slice)
+		return nil
+	}
+	return fmt.Errorf("unsupported reference type %s", value.Type())
+}
+
+// unmarshalValue sets the field value from a property value
+func unmarshalValue(prop any, value reflect.Value, ns NamespaceManager) error {
+	// values that are assignable as they are, such as the typed literals or embedded entities
+	propValue := reflect.ValueOf(prop)
+	if propValue.Type().AssignableTo(value.Type()) {
+		value.Set(propValue)
+		return nil
+	}
+	value = allocate(value)
+	if propValue.Type().AssignableTo(value.Type()) {
+		value.Set(propValue)
+		return nil
+	}
+
+	switch value.Type() {
+	case timeType:
+		if t, ok := toTime(prop); ok {
+			value.Set(reflect.ValueOf(t))
+			return nil
+		}
+		if s, ok := prop.(string); ok {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return err
+			}
+			value.Set(reflect.ValueOf(t))
+			return nil
+		}
+		return fmt.Errorf("cannot unmarshal %T into %s", prop, value.Type())
+	case dateTimeType:
+		if t, ok := toTime(prop); ok {
+			value.Set(reflect.ValueOf(DateTime{t}))
+			return nil
+		}
+		return fmt.Errorf("cannot unmarshal %T into %s", prop, value.Type())
+	case dateType:
+		if t, ok := toTime(prop); ok {
+			value.Set(reflect.ValueOf(Date{t}))
+			return nil
+		}
+		return fmt.Errorf("cannot unmarshal %T into %s", prop, value.Type())
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		switch v := prop.(type) {
+		case string:
+			value.SetString(v)
+		case LangString:
+			value.SetString(v.Value)
+		case Decimal:
+			value.SetString(string(v))
+		default:
+			return fmt.Errorf("cannot unmarshal %T into %s", prop, value.Type())
+		}
+		return nil
+	case reflect.Bool:
+		b, ok := prop.(bool)
+		if !ok {
+			return fmt.Errorf("cannot unmarshal %T into %s", prop, value.Type())
+		}
+		value.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toInt64(prop)
+		if !ok || value.OverflowInt(i) {
+			return fmt.Errorf("cannot unmarshal %v into %s", prop, value.Type())
+		}
+		value.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, ok := toUint64(prop)
+		if !ok || value.OverflowUint(u) {
+			return fmt.Errorf("cannot unmarshal %v into %s", prop, value.Type())
+		}
+		value.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat(prop)
+		if !ok {
+			return fmt.Errorf("cannot unmarshal %T into %s", prop, value.Type())
+		}
+		value.SetFloat(f)
+		return nil
+	case reflect.Struct:
+		switch v := prop.(type) {
+		case *Entity:
+			return unmarshalStruct(v, value, ns)
+		case map[string]any:
+			return unmarshalStruct(newEntityFromMap(v), value, ns)
+		}
+		return fmt.Errorf("cannot unmarshal %T into %s", prop, value.Type())
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			if b, ok := prop.(Binary); ok {
+				value.SetBytes(b)
+				return nil
+			}
+		}
+		var values []any
+		switch v := prop.(type) {
+		case []any:
+			values = v
+		case []string:
+			values = make([]any, len(v))
+			for i, val := range v {
+				values[i] = val
+			}
+		default:
+			// a single value is unmarshalled into a slice of one element
+			values = []any{prop}
+		}
+		slice := reflect.MakeSlice(value.Type(), len(values), len(values))
+		for i, val := range values {
+			if val == nil {
+				continue
+			}
+			if err := unmarshalValue(val, slice.Index(i), ns); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+		return nil
+	}
+	return fmt.Errorf("unsupported property type %s", value.Type())
+}
+
+func parseFieldMapping(field reflect.StructField) (fieldMapping, bool, error) {
+	tag, found := field.Tag.Lookup("egdm")
+	if !found || tag == "-" || !field.IsExported() {
+		return fieldMapping{}, false, nil
+	}
+
+	parts := strings.Split(tag, ",")
+	mapping := fieldMapping{}
+	for _, option := range parts[1:] {
+		if option != "omitempty" {
+			return fieldMapping{}, false, fmt.Errorf("unknown option %s in egdm tag of field %s", option, field.Name)
+		}
+		mapping.omitEmpty = true
+	}
+
+	kind, key, _ := strings.Cut(parts[0], "=")
+	switch kind {
+	case fieldMappingID:
+		mapping.kind = kind
+	case fieldMappingProp, fieldMappingRef:
+		if key == "" {
+			return fieldMapping{}, false, fmt.Errorf("missing key in egdm tag of field %s", field.Name)
+		}
+		mapping.kind = kind
+		mapping.key = key
+	default:
+		return fieldMapping{}, false, fmt.Errorf("invalid egdm tag %s of field %s", tag, field.Name)
+	}
+	return mapping, true, nil
+}
+
+// lookupKey returns the value of the key in the map, comparing the keys as full URIs when a namespace manager is given
+func lookupKey(values map[string]any, key string, ns NamespaceManager) (any, bool) {
+	if value, found := values[key]; found {
+		return value, true
+	}
+	if ns == nil {
+		return nil, false
+	}
+
+	fullKey, err := ns.GetFullURI(key)
+	if err != nil {
+		return nil, false
+	}
+	for k, value := range values {
+		if fullK, err := ns.GetFullURI(k); err == nil && fullK == fullKey {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// checkIdentifier returns the identifier as it is, or an error if the namespace manager can not expand it
+func checkIdentifier(value string, ns NamespaceManager) (string, error) {
+	if ns == nil {
+		return value, nil
+	}
+	if _, err := ns.GetFullURI(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// indirect follows pointers and interfaces, ok is false if a nil value is found
+func indirect(value reflect.Value) (reflect.Value, bool) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return value, false
+		}
+		value = value.Elem()
+	}
+	return value, true
+}
+
+// allocate follows pointers, allocating the values of nil pointers
+func allocate(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
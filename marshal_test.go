@@ -0,0 +1,344 @@
+package egdm
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testAddress struct {
+	Street string `egdm:"prop=ex:street"`
+	City   string `egdm:"prop=ex:city,omitempty"`
+}
+
+type testPerson struct {
+	ID        string         `egdm:"id"`
+	Name      string         `egdm:"prop=ex:name"`
+	Age       int            `egdm:"prop=ex:age"`
+	Height    *float64       `egdm:"prop=ex:height,omitempty"`
+	Tags      []string       `egdm:"prop=ex:tags,omitempty"`
+	Born      time.Time      `egdm:"prop=ex:born"`
+	Address   testAddress    `egdm:"prop=ex:address"`
+	Previous  []*testAddress `egdm:"prop=ex:previousAddress,omitempty"`
+	WorksFor  string         `egdm:"ref=ex:worksFor"`
+	Knows     []string       `egdm:"ref=ex:knows,omitempty"`
+	Internal  string
+	Ignored   string       `egdm:"-"`
+	Nicknames []LangString `egdm:"prop=ex:nickname,omitempty"`
+}
+
+func TestMarshal(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	height := 1.85
+	person := &testPerson{
+		ID:       "ex:1",
+		Name:     "John",
+		Age:      42,
+		Height:   &height,
+		Born:     time.Date(1981, 5, 17, 8, 0, 0, 0, time.UTC),
+		Address:  testAddress{Street: "Main Street"},
+		Previous: []*testAddress{{Street: "Old Street", City: "Oslo"}},
+		WorksFor: "ex:company",
+		Internal: "not mapped",
+		Ignored:  "not mapped",
+	}
+
+	entity, err := Marshal(person, nsManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entity.ID != "ex:1" {
+		t.Errorf("unexpected id %s", entity.ID)
+	}
+	if entity.Properties["ex:name"] != "John" || entity.Properties["ex:age"] != 42 {
+		t.Errorf("unexpected properties %v", entity.Properties)
+	}
+	if entity.Properties["ex:height"] != 1.85 {
+		t.Errorf("expected pointer value to be dereferenced, got %v", entity.Properties["ex:height"])
+	}
+	if _, found := entity.Properties["ex:tags"]; found {
+		t.Errorf("expected empty tags to be omitted")
+	}
+	if entity.Properties["ex:born"] != (DateTime{person.Born}) {
+		t.Errorf("expected time to be a DateTime, got %#v", entity.Properties["ex:born"])
+	}
+	address, ok := entity.Properties["ex:address"].(*Entity)
+	if !ok || address.ID != "" || address.Properties["ex:street"] != "Main Street" {
+		t.Errorf("unexpected address %#v", entity.Properties["ex:address"])
+	}
+	if _, found := address.Properties["ex:city"]; found {
+		t.Errorf("expected empty city to be omitted")
+	}
+	previous, ok := entity.Properties["ex:previousAddress"].([]any)
+	if !ok || len(previous) != 1 || previous[0].(*Entity).Properties["ex:city"] != "Oslo" {
+		t.Errorf("unexpected previous addresses %#v", entity.Properties["ex:previousAddress"])
+	}
+	if entity.References["ex:worksFor"] != "ex:company" {
+		t.Errorf("unexpected references %v", entity.References)
+	}
+	if len(entity.Properties) != 6 || len(entity.References) != 1 {
+		t.Errorf("expected untagged and ignored fields to be skipped, got %v %v", entity.Properties, entity.References)
+	}
+}
+
+func TestMarshalUnknownPrefix(t *testing.T) {
+	_, err := Marshal(testAddress{Street: "Main Street"}, NewNamespaceContext())
+	if err == nil {
+		t.Errorf("expected error for prefix not in namespace manager")
+	}
+}
+
+func TestUnmarshalParsedEntity(t *testing.T) {
+	byteReader := bytes.NewReader([]byte(`
+		[ {"id":"@context","namespaces":{"ex":"http://example.com/"}},
+		  {"id":"ex:1","props":{
+			"ex:name":"John",
+			"ex:age":42,
+			"ex:height":1.85,
+			"ex:tags":["a","b"],
+			"ex:born":{"@value":"1981-05-17T08:00:00Z","@type":"http://www.w3.org/2001/XMLSchema#dateTime"},
+			"ex:address":{"props":{"ex:street":"Main Street"}},
+			"ex:previousAddress":{"props":{"ex:street":"Old Street","ex:city":"Oslo"}},
+			"ex:nickname":{"@value":"Johnny","@language":"en"}
+		  },
+		  "refs":{"ex:worksFor":"ex:company","ex:knows":["ex:2","ex:3"]}}
+		]`))
+
+	ec, err := NewEntityParser(NewNamespaceContext()).LoadEntityCollection(byteReader)
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+
+	person := testPerson{}
+	if err := Unmarshal(ec.Entities[0], &person); err != nil {
+		t.Fatal(err)
+	}
+
+	if person.ID != "ex:1" || person.Name != "John" || person.Age != 42 || person.Height == nil || *person.Height != 1.85 {
+		t.Errorf("unexpected person %+v", person)
+	}
+	if len(person.Tags) != 2 || person.Tags[1] != "b" {
+		t.Errorf("unexpected tags %v", person.Tags)
+	}
+	if !person.Born.Equal(time.Date(1981, 5, 17, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected born %v", person.Born)
+	}
+	if person.Address.Street != "Main Street" {
+		t.Errorf("unexpected address %+v", person.Address)
+	}
+	if len(person.Previous) != 1 || person.Previous[0].City != "Oslo" {
+		t.Errorf("expected single value to be unmarshalled into slice, got %+v", person.Previous)
+	}
+	if person.WorksFor != "ex:company" || len(person.Knows) != 2 || person.Knows[1] != "ex:3" {
+		t.Errorf("unexpected references %s %v", person.WorksFor, person.Knows)
+	}
+	if len(person.Nicknames) != 1 || person.Nicknames[0].Value != "Johnny" {
+		t.Errorf("unexpected nicknames %v", person.Nicknames)
+	}
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	person := testPerson{
+		ID:       "ex:1",
+		Name:     "John",
+		Age:      42,
+		Tags:     []string{"a"},
+		Born:     time.Date(1981, 5, 17, 8, 0, 0, 0, time.UTC),
+		Address:  testAddress{Street: "Main Street"},
+		WorksFor: "ex:company",
+		Knows:    []string{"ex:2"},
+	}
+	entity, err := Marshal(person, nsManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := testPerson{}
+	if err := Unmarshal(entity, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, person) {
+		t.Errorf("expected %+v after round trip, got %+v", person, result)
+	}
+}
+
+func TestUnmarshalWithNamespaceManager(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	entity, err := Marshal(testPerson{ID: "ex:1", Name: "John", Tags: []string{"a"}, Knows: []string{"ex:2"}}, nsManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.ExpandNamespacePrefixes(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tags are compared with the expanded keys of the entity
+	result := testPerson{}
+	if err := UnmarshalWithNamespaceManager(entity, &result, nsManager); err != nil {
+		t.Fatal(err)
+	}
+	if result.ID != "http://example.com/1" || result.Name != "John" || len(result.Tags) != 1 || result.Knows[0] != "http://example.com/2" {
+		t.Errorf("unexpected person %+v", result)
+	}
+
+	// without a namespace manager the keys must match the tags as they are
+	result = testPerson{}
+	if err := Unmarshal(entity, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Name != "" {
+		t.Errorf("expected no match for expanded keys, got %+v", result)
+	}
+}
+
+func TestUnmarshalTypeMismatch(t *testing.T) {
+	entity := NewEntity().SetProperty("ex:age", "old")
+	person := testPerson{}
+	if err := Unmarshal(entity, &person); err == nil {
+		t.Errorf("expected error for string into int field")
+	}
+	if err := Unmarshal(entity, person); err == nil {
+		t.Errorf("expected error for non pointer value")
+	}
+}
+
+func TestUnmarshalIntegerRange(t *testing.T) {
+	type counters struct {
+		Small  int8   `egdm:"prop=ex:small"`
+		Count  int64  `egdm:"prop=ex:count"`
+		Size   uint8  `egdm:"prop=ex:size"`
+		Serial uint64 `egdm:"prop=ex:serial"`
+	}
+
+	entity := NewEntity().
+		SetProperty("ex:small", 3.0).
+		SetProperty("ex:count", json.Number("9223372036854775807")).
+		SetProperty("ex:size", uint64(200)).
+		SetProperty("ex:serial", json.Number("18446744073709551615"))
+	result := counters{}
+	if err := Unmarshal(entity, &result); err != nil {
+		t.Fatal(err)
+	}
+	expected := counters{Small: 3, Count: math.MaxInt64, Size: 200, Serial: math.MaxUint64}
+	if result != expected {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+
+	invalid := map[string]any{
+		"ex:small":  1.5,
+		"ex:count":  uint64(math.MaxInt64) + 1,
+		"ex:size":   uint64(256),
+		"ex:serial": -1,
+	}
+	for key, value := range invalid {
+		entity := NewEntity().SetProperty(key, value)
+		if err := Unmarshal(entity, &counters{}); err == nil {
+			t.Errorf("expected error for %v in %s", value, key)
+		}
+	}
+	for _, value := range []any{json.Number("1.5"), json.Number("1e30"), 2.5} {
+		entity := NewEntity().SetProperty("ex:count", value)
+		if err := Unmarshal(entity, &counters{}); err == nil {
+			t.Errorf("expected error for %v in ex:count", value)
+		}
+	}
+}
+
+func TestMarshalByteArray(t *testing.T) {
+	type checksum struct {
+		Sum [4]byte `egdm:"prop=ex:sum"`
+		Raw []byte  `egdm:"prop=ex:raw"`
+	}
+
+	// a struct value, not a pointer, so the array field is not addressable
+	entity, err := Marshal(checksum{Sum: [4]byte{1, 2, 3, 4}, Raw: []byte{5}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum, ok := entity.Properties["ex:sum"].(Binary); !ok || !bytes.Equal(sum, []byte{1, 2, 3, 4}) {
+		t.Errorf("expected array to be marshalled as Binary, got %#v", entity.Properties["ex:sum"])
+	}
+	if raw, ok := entity.Properties["ex:raw"].(Binary); !ok || !bytes.Equal(raw, []byte{5}) {
+		t.Errorf("expected slice to be marshalled as Binary, got %#v", entity.Properties["ex:raw"])
+	}
+}
+
+func TestMarshalCopiesEntityFields(t *testing.T) {
+	type wrapper struct {
+		Embedded Entity  `egdm:"prop=ex:embedded"`
+		Pointer  *Entity `egdm:"prop=ex:pointer"`
+	}
+	value := &wrapper{
+		Embedded: *NewEntity().SetID("ex:1").SetProperty("ex:name", "one"),
+		Pointer:  NewEntity().SetID("ex:2"),
+	}
+
+	entity, err := Marshal(value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded := entity.Properties["ex:embedded"].(*Entity)
+	if embedded == &value.Embedded || embedded.ID != "ex:1" || embedded.Properties["ex:name"] != "one" {
+		t.Errorf("expected a copy of the entity field, got %#v", embedded)
+	}
+	embedded.ID = "ex:changed"
+	embedded.SetProperty("ex:name", "changed")
+	if value.Embedded.ID != "ex:1" || value.Embedded.Properties["ex:name"] != "one" {
+		t.Errorf("expected the struct not to change, got %#v", value.Embedded)
+	}
+	if pointer := entity.Properties["ex:pointer"].(*Entity); pointer == value.Pointer || pointer.ID != "ex:2" {
+		t.Errorf("expected a copy of the entity pointer field, got %#v", pointer)
+	}
+}
+
+type testNode struct {
+	Name     string      `egdm:"prop=ex:name"`
+	Next     *testNode   `egdm:"prop=ex:next,omitempty"`
+	Children []testNode  `egdm:"prop=ex:children,omitempty"`
+	Shared   []*testNode `egdm:"prop=ex:shared,omitempty"`
+}
+
+func TestMarshalCycle(t *testing.T) {
+	self := &testNode{Name: "self"}
+	self.Next = self
+	if _, err := Marshal(self, nil); err == nil {
+		t.Errorf("expected error for pointer to itself")
+	}
+
+	first := &testNode{Name: "first"}
+	first.Next = &testNode{Name: "second", Next: first}
+	if _, err := Marshal(first, nil); err == nil {
+		t.Errorf("expected error for pointer cycle")
+	}
+
+	children := make([]testNode, 1)
+	children[0] = testNode{Name: "child", Children: children}
+	if _, err := Marshal(testNode{Name: "parent", Children: children}, nil); err == nil {
+		t.Errorf("expected error for slice cycle")
+	}
+
+	// a value that is used twice without containing itself is not a cycle
+	leaf := &testNode{Name: "leaf"}
+	entity, err := Marshal(testNode{Name: "root", Next: leaf, Shared: []*testNode{leaf, leaf}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared := entity.Properties["ex:shared"].([]any); len(shared) != 2 || shared[1].(*Entity).Properties["ex:name"] != "leaf" {
+		t.Errorf("unexpected shared values %#v", entity.Properties["ex:shared"])
+	}
+}
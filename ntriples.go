@@ -0,0 +1,369 @@
+package egdm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RdfNamespaceExpansion  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	XsdNamespaceExpansion  = "http://www.w3.org/2001/XMLSchema#"
+	CoreNamespaceExpansion = "http://data.mimiro.io/core/uda/"
+
+	RdfTypeURI = RdfNamespaceExpansion + "type"
+
+	xsdString  = XsdNamespaceExpansion + "string"
+	xsdBoolean = XsdNamespaceExpansion + "boolean"
+	xsdInteger = XsdNamespaceExpansion + "integer"
+	xsdDouble  = XsdNamespaceExpansion + "double"
+
+	// DeletedMarkerURI is the predicate used for the deleted marker triple
+	DeletedMarkerURI = CoreNamespaceExpansion + "deleted"
+)
+
+// NTriplesWriter writes an EntityCollection as N-Triples, or as N-Quads when a graph is set.
+// All identifiers are expanded to full URIs using the namespace manager of the collection.
+type NTriplesWriter struct {
+	graph          string
+	emitDeleted    bool
+	blankNodeCount int
+}
+
+func NewNTriplesWriter() *NTriplesWriter {
+	return &NTriplesWriter{}
+}
+
+// WithGraph sets the graph written as the fourth element of each statement, making the output N-Quads
+func (ntWriter *NTriplesWriter) WithGraph(graph string) *NTriplesWriter {
+	ntWriter.graph = graph
+	return ntWriter
+}
+
+// WithDeletedMarker configures the writer to emit a DeletedMarkerURI triple for entities that are deleted
+func (ntWriter *NTriplesWriter) WithDeletedMarker() *NTriplesWriter {
+	ntWriter.emitDeleted = true
+	return ntWriter
+}
+
+func (ntWriter *NTriplesWriter) Write(ec *EntityCollection, writer io.Writer) error {
+	ntWriter.blankNodeCount = 0
+
+	graph := ""
+	if ntWriter.graph != "" {
+		graphURI, err := ec.NamespaceManager.GetFullURI(ntWriter.graph)
+		if err != nil {
+			return err
+		}
+		graph = formatIRI(graphURI)
+	}
+
+	for _, entity := range ec.Entities {
+		statements := &strings.Builder{}
+		_, err := ntWriter.writeEntity(ec.NamespaceManager, entity, graph, statements)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write([]byte(statements.String()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeEntity writes the statements of the entity and returns the subject term it was written with
+func (ntWriter *NTriplesWriter) writeEntity(nsManager NamespaceManager, entity *Entity, graph string, statements *strings.Builder) (string, error) {
+	var subject string
+	if entity.ID == "" {
+		subject = fmt.Sprintf("_:b%d", ntWriter.blankNodeCount)
+		ntWriter.blankNodeCount++
+	} else {
+		id, err := nsManager.GetFullURI(entity.ID)
+		if err != nil {
+			return "", err
+		}
+		subject = formatIRI(id)
+	}
+
+	if ntWriter.emitDeleted && entity.IsDeleted {
+		writeStatement(statements, subject, formatIRI(DeletedMarkerURI), formatLiteral("true", xsdBoolean), graph)
+	}
+
+	for _, key := range sortedKeys(entity.References) {
+		predicate, err := nsManager.GetFullURI(key)
+		if err != nil {
+			return "", err
+		}
+		values, err := referenceValues(entity.References[key])
+		if err != nil {
+			return "", err
+		}
+		for _, value := range values {
+			ref, err := nsManager.GetFullURI(value)
+			if err != nil {
+				return "", err
+			}
+			writeStatement(statements, subject, formatIRI(predicate), formatIRI(ref), graph)
+		}
+	}
+
+	for _, key := range sortedKeys(entity.Properties) {
+		predicate, err := nsManager.GetFullURI(key)
+		if err != nil {
+			return "", err
+		}
+		err = ntWriter.writePropertyValue(nsManager, subject, formatIRI(predicate), entity.Properties[key], graph, statements)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return subject, nil
+}
+
+func (ntWriter *NTriplesWriter) writePropertyValue(nsManager NamespaceManager, subject string, predicate string, value any, graph string, statements *strings.Builder) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		for _, val := range v {
+			err := ntWriter.writePropertyValue(nsManager, subject, predicate, val, graph, statements)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case []string:
+		for _, val := range v {
+			writeStatement(statements, subject, predicate, formatLiteral(val, xsdString), graph)
+		}
+		return nil
+	case []*Entity:
+		for _, val := range v {
+			err := ntWriter.writePropertyValue(nsManager, subject, predicate, val, graph, statements)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		return ntWriter.writePropertyValue(nsManager, subject, predicate, newEntityFromMap(v), graph, statements)
+	case *Entity:
+		// the statements of the embedded entity are written ahead of the statement referencing it
+		object, err := ntWriter.writeEntity(nsManager, v, graph, statements)
+		if err != nil {
+			return err
+		}
+		writeStatement(statements, subject, predicate, object, graph)
+		return nil
+	case LangString:
+		writeStatement(statements, subject, predicate, formatLangLiteral(v), graph)
+		return nil
+	case JsonLdValue:
+		lexical, datatype, err := jsonLdValueLiteral(nsManager, v)
+		if err != nil {
+			return err
+		}
+		if v.Language != "" {
+			writeStatement(statements, subject, predicate, formatLangLiteral(LangString{Value: lexical, Language: v.Language}), graph)
+		} else {
+			writeStatement(statements, subject, predicate, formatLiteral(lexical, datatype), graph)
+		}
+		return nil
+	}
+
+	lexical, datatype, err := literalOf(value)
+	if err != nil {
+		return err
+	}
+	writeStatement(statements, subject, predicate, formatLiteral(lexical, datatype), graph)
+	return nil
+}
+
+// jsonLdValueLiteral returns the lexical form and datatype URI of a value object. The type is expanded with the
+// namespace manager, a value object without a type has the datatype of its value.
+func jsonLdValueLiteral(nsManager NamespaceManager, value JsonLdValue) (string, string, error) {
+	lexical, datatype, err := literalOf(value.Value)
+	if err != nil {
+		return "", "", err
+	}
+	if value.Type == "" {
+		return lexical, datatype, nil
+	}
+	datatype, err = nsManager.GetFullURI(value.Type)
+	if err != nil {
+		return "", "", err
+	}
+	return lexical, datatype, nil
+}
+
+// literalOf returns the lexical form and datatype URI of a scalar property value
+func literalOf(value any) (string, string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, xsdString, nil
+	case bool:
+		return strconv.FormatBool(v), xsdBoolean, nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), xsdInteger, nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), xsdInteger, nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), xsdInteger, nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), xsdInteger, nil
+	case int64:
+		return strconv.FormatInt(v, 10), xsdInteger, nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), xsdInteger, nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), xsdInteger, nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), xsdInteger, nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), xsdInteger, nil
+	case uint64:
+		return strconv.FormatUint(v, 10), xsdInteger, nil
+	case float32:
+		return formatDouble(float64(v)), xsdDouble, nil
+	case float64:
+		return formatDouble(v), xsdDouble, nil
+	case json.Number:
+		// json.Number values can be built from any string, values that are not numbers are not written
+		lexical := v.String()
+		if !strings.ContainsAny(lexical, ".eE") {
+			if !isDecimalLexical(lexical) {
+				return "", "", fmt.Errorf("invalid json.Number value %q", lexical)
+			}
+			return lexical, xsdInteger, nil
+		}
+		if !isDoubleLexical(lexical) {
+			return "", "", fmt.Errorf("invalid json.Number value %q", lexical)
+		}
+		return lexical, xsdDouble, nil
+	case *big.Float:
+		if v.IsInt() {
+			return v.Text('f', 0), xsdInteger, nil
+		}
+		return v.Text('g', -1), xsdDouble, nil
+	case DateTime:
+		return v.Format(time.RFC3339Nano), xsdDateTime, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), xsdDateTime, nil
+	case Date:
+		return v.Format(xsdDateLayout), xsdDate, nil
+	case Decimal:
+		return string(v), xsdDecimal, nil
+	case Binary:
+		return base64.StdEncoding.EncodeToString(v), xsdBase64Binary, nil
+	}
+	return "", "", fmt.Errorf("unsupported property value type %T", value)
+}
+
+func formatDouble(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "INF"
+	case math.IsInf(value, -1):
+		return "-INF"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// referenceValues returns the values of a reference as a slice of strings
+func referenceValues(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, val := range v {
+			ref, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected type %T in refs", val)
+			}
+			values = append(values, ref)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unexpected type %T in refs", value)
+}
+
+func writeStatement(statements *strings.Builder, subject string, predicate string, object string, graph string) {
+	statements.WriteString(subject)
+	statements.WriteString(" ")
+	statements.WriteString(predicate)
+	statements.WriteString(" ")
+	statements.WriteString(object)
+	if graph != "" {
+		statements.WriteString(" ")
+		statements.WriteString(graph)
+	}
+	statements.WriteString(" .\n")
+}
+
+func formatIRI(iri string) string {
+	builder := strings.Builder{}
+	builder.WriteString("<")
+	for _, r := range iri {
+		switch {
+		case r <= 0x20, r == '<', r == '>', r == '"', r == '{', r == '}', r == '|', r == '^', r == '`', r == '\\':
+			builder.WriteString(fmt.Sprintf("\\u%04X", r))
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	builder.WriteString(">")
+	return builder.String()
+}
+
+func formatLiteral(lexical string, datatype string) string {
+	return "\"" + escapeLiteral(lexical) + "\"^^" + formatIRI(datatype)
+}
+
+func formatLangLiteral(value LangString) string {
+	return "\"" + escapeLiteral(value.Value) + "\"@" + value.Language
+}
+
+func escapeLiteral(value string) string {
+	builder := strings.Builder{}
+	for _, r := range value {
+		switch r {
+		case '"':
+			builder.WriteString("\\\"")
+		case '\\':
+			builder.WriteString("\\\\")
+		case '\n':
+			builder.WriteString("\\n")
+		case '\r':
+			builder.WriteString("\\r")
+		case '\t':
+			builder.WriteString("\\t")
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+func sortedKeys(values map[string]any) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,153 @@
+package egdm
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteNTriples(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	nsManager.StorePrefixExpansionMapping("rdf", "http://www.w3.org/1999/02/22-rdf-syntax-ns#")
+
+	address := NewEntity()
+	address.SetProperty("ex:street", "123 \"Main\" Street")
+
+	entity := NewEntity().SetID("ex:1")
+	entity.SetProperty("ex:name", "John Smith")
+	entity.SetProperty("ex:active", true)
+	entity.SetProperty("ex:age", 42)
+	entity.SetProperty("ex:height", 1.85)
+	entity.SetProperty("ex:address", address)
+	entity.SetProperty("ex:tags", []any{"a", "b"})
+	entity.SetReference("rdf:type", "ex:Person")
+	entity.SetReference("ex:knows", []string{"ex:2", "http://example.com/3"})
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteNTriples(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<http://example.com/1> <http://example.com/knows> <http://example.com/2> .
+<http://example.com/1> <http://example.com/knows> <http://example.com/3> .
+<http://example.com/1> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.com/Person> .
+<http://example.com/1> <http://example.com/active> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+_:b0 <http://example.com/street> "123 \"Main\" Street"^^<http://www.w3.org/2001/XMLSchema#string> .
+<http://example.com/1> <http://example.com/address> _:b0 .
+<http://example.com/1> <http://example.com/age> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.com/1> <http://example.com/height> "1.85"^^<http://www.w3.org/2001/XMLSchema#double> .
+<http://example.com/1> <http://example.com/name> "John Smith"^^<http://www.w3.org/2001/XMLSchema#string> .
+<http://example.com/1> <http://example.com/tags> "a"^^<http://www.w3.org/2001/XMLSchema#string> .
+<http://example.com/1> <http://example.com/tags> "b"^^<http://www.w3.org/2001/XMLSchema#string> .
+`
+	if buffer.String() != expected {
+		t.Errorf("unexpected n-triples output:\n%s", buffer.String())
+	}
+}
+
+func TestWriteNQuadsWithDeletedMarkerAndNamedEmbeddedEntity(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	entity := NewEntity().SetID("ex:1")
+	entity.IsDeleted = true
+	entity.SetProperty("ex:address", NewEntity().SetID("ex:address1").SetProperty("ex:street", "Main Street"))
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := NewNTriplesWriter().WithGraph("ex:graph").WithDeletedMarker().Write(ec, &buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<http://example.com/1> <http://data.mimiro.io/core/uda/deleted> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> <http://example.com/graph> .
+<http://example.com/address1> <http://example.com/street> "Main Street"^^<http://www.w3.org/2001/XMLSchema#string> <http://example.com/graph> .
+<http://example.com/1> <http://example.com/address> <http://example.com/address1> <http://example.com/graph> .
+`
+	if buffer.String() != expected {
+		t.Errorf("unexpected n-quads output:\n%s", buffer.String())
+	}
+
+	// without the marker option deleted entities are written without the marker
+	buffer.Reset()
+	if err := ec.WriteNQuads(&buffer, "http://example.com/graph"); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buffer.Bytes(), []byte(DeletedMarkerURI)) {
+		t.Errorf("expected no deleted marker, got:\n%s", buffer.String())
+	}
+}
+
+func TestWriteNTriplesFailsForUnknownPrefix(t *testing.T) {
+	ec := NewEntityCollection(nil)
+	if err := ec.AddEntity(NewEntity().SetID("ex:1").SetProperty("ex:name", "John")); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteNTriples(&buffer); err == nil {
+		t.Error("expected error for unknown prefix")
+	}
+}
+
+func TestWriteNTriplesFailsForInvalidJSONNumber(t *testing.T) {
+	for _, number := range []json.Number{"12 ; <x> <y>", "1.5 .", "NaN", ""} {
+		ec := NewEntityCollection(nil)
+		if err := ec.AddEntity(NewEntity().SetID("http://example.com/1").SetProperty("http://example.com/value", number)); err != nil {
+			t.Fatal(err)
+		}
+
+		buffer := bytes.Buffer{}
+		if err := ec.WriteNTriples(&buffer); err == nil {
+			t.Errorf("expected error for %q, got:\n%s", number, buffer.String())
+		}
+	}
+
+	ec := NewEntityCollection(nil)
+	if err := ec.AddEntity(NewEntity().SetID("http://example.com/1").SetProperty("http://example.com/value", json.Number("1e400"))); err != nil {
+		t.Fatal(err)
+	}
+	buffer := bytes.Buffer{}
+	if err := ec.WriteNTriples(&buffer); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteNTriplesOfParsedCustomDatatypes(t *testing.T) {
+	data := `[{"id":"@context","namespaces":{"ex":"http://example.com/"}},
+		{"id":"ex:1","props":{
+			"ex:full":{"@value":"abc","@type":"http://example.com/custom"},
+			"ex:curie":{"@value":"def","@type":"ex:other"},
+			"ex:number":{"@value":12,"@type":"ex:amount"}
+		}}]`
+	ec, err := NewEntityParser(NewNamespaceContext()).LoadEntityCollection(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec.Entities[0].SetProperty("ex:lang", JsonLdValue{Value: "hei", Language: "no"})
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteNTriples(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		`<http://example.com/1> <http://example.com/full> "abc"^^<http://example.com/custom> .`,
+		`<http://example.com/1> <http://example.com/curie> "def"^^<http://example.com/other> .`,
+		`<http://example.com/1> <http://example.com/number> "12"^^<http://example.com/amount> .`,
+		`<http://example.com/1> <http://example.com/lang> "hei"@no .`,
+	} {
+		if !strings.Contains(buffer.String(), statement) {
+			t.Errorf("expected %s in:\n%s", statement, buffer.String())
+		}
+	}
+}
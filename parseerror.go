@@ -0,0 +1,131 @@
+package egdm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrMissingPrefix is wrapped by errors for identifiers that use a prefix without an expansion
+	ErrMissingPrefix = errors.New("no expansion for prefix")
+	// ErrBadContext is wrapped by errors for a missing or invalid @context object
+	ErrBadContext = errors.New("bad context")
+	// ErrUnexpectedToken is wrapped by errors for JSON that does not have the structure of an Entity Graph document
+	ErrUnexpectedToken = errors.New("unexpected token")
+	// ErrUnknownKey is wrapped by errors for entity keys that are not known to a parser with strict keys
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrInvalidValue is wrapped by errors for values that have the expected structure but can not be used, such as a
+	// recorded value that is not a number
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// ParseError is returned by the EntityParser when a document can not be parsed. Err wraps one of the sentinel errors,
+// such as ErrMissingPrefix, or the error of the JSON decoder.
+type ParseError struct {
+	// Offset is the byte offset in the input after the last token that was read
+	Offset int64
+	// EntityIndex is the zero based index of the entity in the document, the @context is not counted. It is -1 for
+	// errors outside an entity.
+	EntityIndex int
+	// EntityID is the id of the entity as written in the document, if the id was read before the error
+	EntityID string
+	// Path is the path of keys and array indexes from the entity to the value, such as props/ex:address/props/ex:street
+	Path string
+	Err  error
+}
+
+func (parseError *ParseError) Error() string {
+	builder := strings.Builder{}
+	builder.WriteString("parsing error")
+	if parseError.EntityIndex >= 0 {
+		fmt.Fprintf(&builder, " in entity %d", parseError.EntityIndex)
+		if parseError.EntityID != "" {
+			fmt.Fprintf(&builder, " (%s)", parseError.EntityID)
+		}
+	}
+	if parseError.Path != "" {
+		fmt.Fprintf(&builder, " at %s", parseError.Path)
+	}
+	fmt.Fprintf(&builder, " offset %d: %v", parseError.Offset, parseError.Err)
+	return builder.String()
+}
+
+func (parseError *ParseError) Unwrap() error {
+	return parseError.Err
+}
+
+// parseState holds the position of the EntityParser in the document while it is parsed
+type parseState struct {
+	decoder *json.Decoder
+	// offset is the offset in the document of the input of the decoder
+	offset      int64
+	entityIndex int
+	entityID    string
+	path        []string
+	limits      ParserLimits
+	depth       int
+	// prefixRenames maps the prefixes of the document that were renamed in the namespace manager to their new prefix
+	prefixRenames map[string]string
+}
+
+func newParseState(decoder *json.Decoder, limits ParserLimits) *parseState {
+	return &parseState{decoder: decoder, entityIndex: -1, limits: limits}
+}
+
+// token reads the next token, read errors are returned as a ParseError
+func (state *parseState) token() (json.Token, error) {
+	t, err := state.decoder.Token()
+	if err != nil {
+		return nil, state.wrap(fmt.Errorf("unable to read token: %w", err))
+	}
+	switch v := t.(type) {
+	case string:
+		err = state.checkLimit("MaxStringLength", state.limits.MaxStringLength, len(v))
+	case json.Number:
+		err = state.checkLimit("MaxStringLength", state.limits.MaxStringLength, len(v))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// errorf returns a ParseError for the current position with an error wrapping the kind
+func (state *parseState) errorf(kind error, format string, args ...any) error {
+	return state.wrap(fmt.Errorf("%w: %s", kind, fmt.Sprintf(format, args...)))
+}
+
+// wrap returns the error as a ParseError for the current position. A ParseError is returned as it is, so that the
+// position is the one where the error was found.
+func (state *parseState) wrap(err error) error {
+	var parseError *ParseError
+	if errors.As(err, &parseError) {
+		return err
+	}
+	return &ParseError{
+		Offset:      state.offset + state.decoder.InputOffset(),
+		EntityIndex: state.entityIndex,
+		EntityID:    state.entityID,
+		Path:        strings.Join(state.path, "/"),
+		Err:         err,
+	}
+}
+
+// push adds a key or array index to the path
+func (state *parseState) push(segment string) {
+	state.path = append(state.path, segment)
+}
+
+func (state *parseState) pop() {
+	state.path = state.path[:len(state.path)-1]
+}
+
+// startEntity resets the state for the next entity in the document
+func (state *parseState) startEntity() {
+	state.entityIndex++
+	state.entityID = ""
+	state.path = state.path[:0]
+	state.depth = 0
+}
@@ -1,10 +1,14 @@
 package egdm
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -14,13 +18,57 @@ type Parser interface {
 	GetNamespaceManager() NamespaceManager
 }
 
+// ContextParser is a Parser that stops when a context is done. It is implemented by the parsers of this package, the
+// Parser interface is unchanged so that other implementations of it keep working.
+type ContextParser interface {
+	Parser
+	// ParseContext is Parse but checks the context between entities and returns ctx.Err() when it is done
+	ParseContext(ctx context.Context, data io.Reader, entity func(*Entity) error, continuation func(*Continuation)) error
+	// LoadEntityCollectionContext is LoadEntityCollection but returns ctx.Err() when the context is done
+	LoadEntityCollectionContext(ctx context.Context, reader io.Reader) (*EntityCollection, error)
+}
+
 type EntityParser struct {
 	nsManager             NamespaceManager
 	expandURIs            bool
 	compressURIs          bool
 	requireContext        bool
 	lenientNamespaceCheck bool
+	lineDelimited         bool
+	numberMode            numberMode
 	contextParsedCallback func(*Context)
+	prefixConflictPolicy  PrefixConflictPolicy
+	prefixConflicts       func([]PrefixConflict)
+	entityErrorHandler    EntityErrorHandler
+	limits                ParserLimits
+	unknownKeyMode        unknownKeyMode
+}
+
+// unknownKeyMode decides what the parser does with entity keys it does not know
+type unknownKeyMode int
+
+const (
+	unknownKeysSkip unknownKeyMode = iota
+	unknownKeysReject
+	unknownKeysPreserve
+)
+
+type numberMode int
+
+const (
+	numberModeFloat64 numberMode = iota
+	numberModeJSONNumber
+	numberModeExact
+)
+
+// emitWhileNotDone returns an entity callback that returns ctx.Err() instead of emitting when the context is done
+func emitWhileNotDone(ctx context.Context, emitEntity func(*Entity) error) func(*Entity) error {
+	return func(entity *Entity) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return emitEntity(entity)
+	}
 }
 
 func NewEntityParser(nsmanager NamespaceManager) *EntityParser {
@@ -52,18 +100,93 @@ func (esp *EntityParser) WithCompressURIs() *EntityParser {
 	return esp
 }
 
+// WithNDJSON configures the parser to read the line delimited variant of Entity Graph JSON. The first line is the
+// @context object, each following line is an entity and the optional last line is the @continuation. A line with more
+// than one value is an error.
+func (esp *EntityParser) WithNDJSON() *EntityParser {
+	esp.lineDelimited = true
+	return esp
+}
+
+// WithJSONNumbers configures the parser to keep number values as json.Number instead of converting them to float64
+func (esp *EntityParser) WithJSONNumbers() *EntityParser {
+	esp.numberMode = numberModeJSONNumber
+	return esp
+}
+
+// WithExactNumbers configures the parser to convert number values to int64, uint64, float64 or *big.Float, using the
+// first of these that holds the value exactly. Numbers that none of them can hold exactly are kept as json.Number.
+// Note that *big.Float values are written as JSON strings by encoding/json.
+func (esp *EntityParser) WithExactNumbers() *EntityParser {
+	esp.numberMode = numberModeExact
+	return esp
+}
+
+// WithStrictKeys configures the parser to fail with an error wrapping ErrUnknownKey when an entity has a key other
+// than id, recorded, deleted, internalId, props and refs, so that typos such as prop instead of props are found. By
+// default unknown keys and their values are skipped.
+func (esp *EntityParser) WithStrictKeys() *EntityParser {
+	esp.unknownKeyMode = unknownKeysReject
+	return esp
+}
+
+// WithPreservedKeys configures the parser to keep the unknown keys of an entity, and their values, in the Extensions
+// of the entity so that they are written back out with the entity. Objects are kept as map[string]any, arrays as
+// []any and numbers as json.Number.
+func (esp *EntityParser) WithPreservedKeys() *EntityParser {
+	esp.unknownKeyMode = unknownKeysPreserve
+	return esp
+}
+
 func (esp *EntityParser) WithParsedContextCallback(callback func(context *Context)) *EntityParser {
 	esp.contextParsedCallback = callback
 	return esp
 }
 
+// WithPrefixConflictPolicy sets the policy used when a prefix in the parsed @context is already bound to a different
+// expansion in the namespace manager. With PrefixConflictRename the identifiers in the document that use the prefix
+// are rewritten to the prefix the expansion was bound to.
+func (esp *EntityParser) WithPrefixConflictPolicy(policy PrefixConflictPolicy) *EntityParser {
+	esp.prefixConflictPolicy = policy
+	return esp
+}
+
+// WithPrefixConflictCallback registers a callback that is called with the prefix conflicts found while the parsed
+// @context was stored in the namespace manager. The callback is not called if there were no conflicts.
+func (esp *EntityParser) WithPrefixConflictCallback(callback func(conflicts []PrefixConflict)) *EntityParser {
+	esp.prefixConflicts = callback
+	return esp
+}
+
+// WithEntityErrorHandler configures the parser to pass entities that can not be parsed to the handler. When the
+// handler returns nil the entity is skipped and the parser continues with the next entity. The entities of an array
+// document are found by matching brackets, an entity with mismatched brackets ends at the bracket that does not match
+// and the parser continues at the next entity. In a line delimited document each line is an entity. Errors before the
+// first entity, such as an invalid @context, and a document that ends inside an entity still stop the parser.
+func (esp *EntityParser) WithEntityErrorHandler(handler EntityErrorHandler) *EntityParser {
+	esp.entityErrorHandler = handler
+	return esp
+}
+
+// WithLimits sets the limits on the size and nesting of parsed documents. A document that exceeds a limit fails with
+// a ParseError wrapping a LimitExceededError.
+func (esp *EntityParser) WithLimits(limits ParserLimits) *EntityParser {
+	esp.limits = limits
+	return esp
+}
+
 func (esp *EntityParser) GetNamespaceManager() NamespaceManager {
 	return esp.nsManager
 }
 
 func (esp *EntityParser) LoadEntityCollection(reader io.Reader) (*EntityCollection, error) {
+	return esp.LoadEntityCollectionContext(context.Background(), reader)
+}
+
+// LoadEntityCollectionContext is LoadEntityCollection but returns ctx.Err() when the context is done
+func (esp *EntityParser) LoadEntityCollectionContext(ctx context.Context, reader io.Reader) (*EntityCollection, error) {
 	ec := NewEntityCollection(esp.nsManager)
-	err := esp.Parse(reader, func(e *Entity) error {
+	err := esp.ParseContext(ctx, reader, func(e *Entity) error {
 		return ec.AddEntity(e)
 	}, func(c *Continuation) {
 		ec.SetContinuationToken(c)
@@ -75,15 +198,16 @@ func (esp *EntityParser) LoadEntityCollection(reader io.Reader) (*EntityCollecti
 }
 
 func (esp *EntityParser) GetIdentityValue(value string) (string, error) {
+	return esp.identityValue(value, nil)
+}
+
+// identityValue is GetIdentityValue for an identifier of a document whose renamed prefixes are in prefixRenames
+func (esp *EntityParser) identityValue(value string, prefixRenames map[string]string) (string, error) {
+	value = renamePrefix(esp.nsManager, prefixRenames, value)
 	identity := value
-	var err error
 
 	if esp.compressURIs {
-		identity, err = esp.nsManager.AssertPrefixedIdentifierFromURI(value)
-		if err != nil {
-			return "", err
-		}
-		return identity, nil
+		return compressIdentifier(esp.nsManager, value)
 	}
 
 	if esp.expandURIs {
@@ -107,187 +231,417 @@ func (esp *EntityParser) GetIdentityValue(value string) (string, error) {
 	}
 
 	if !esp.nsManager.DoesExpansionExistForPrefix(prefix) {
-		return "", fmt.Errorf("no expansion for prefix: %s", prefix)
+		return "", fmt.Errorf("%w: %s", ErrMissingPrefix, prefix)
 	}
 
 	return esp.nsManager.GetPrefixedIdentifier(identity)
 }
 
+// compressIdentifier returns the URI as a prefixed identifier, storing a generated prefix for the expansion if needed
+func compressIdentifier(nsManager NamespaceManager, value string) (string, error) {
+	// IRIs without a '/' or '#', such as urn:uuid identifiers, can only use a declared prefix
+	if nsManager.IsFullUri(value) && !isHierarchicalIRI(value) {
+		if identity, err := nsManager.GetPrefixedIdentifier(value); err == nil {
+			return identity, nil
+		}
+		return value, nil
+	}
+	return nsManager.AssertPrefixedIdentifierFromURI(value)
+}
+
+// renamePrefix rewrites an identifier that uses a prefix of the document that was renamed in the namespace manager
+func renamePrefix(nsManager NamespaceManager, prefixRenames map[string]string, value string) string {
+	if len(prefixRenames) == 0 || nsManager.IsFullUri(value) {
+		return value
+	}
+	prefix, local, isCURIE := strings.Cut(value, ":")
+	if !isCURIE {
+		prefix, local = "_", value
+	}
+	if renamed, found := prefixRenames[prefix]; found {
+		return renamed + ":" + local
+	}
+	return value
+}
+
 func (esp *EntityParser) Parse(reader io.Reader, emitEntity func(*Entity) error, emitContinuation func(*Continuation)) error {
+	return esp.ParseContext(context.Background(), reader, emitEntity, emitContinuation)
+}
+
+// ParseContext is Parse but checks the context between entities and returns ctx.Err() when it is done
+func (esp *EntityParser) ParseContext(ctx context.Context, reader io.Reader, emitEntity func(*Entity) error, emitContinuation func(*Continuation)) error {
+	_, err := esp.ParseWithSummary(ctx, reader, emitEntity, emitContinuation)
+	return err
+}
+
+// ParseWithSummary is ParseContext but also returns the number of entities parsed and the entities skipped by the
+// entity error handler. The summary is returned when parsing fails too, it then describes the document up to the error.
+func (esp *EntityParser) ParseWithSummary(ctx context.Context, reader io.Reader, emitEntity func(*Entity) error, emitContinuation func(*Continuation)) (ParseSummary, error) {
+	summary := ParseSummary{}
+	err := esp.parse(ctx, reader, emitEntity, emitContinuation, &summary)
+	return summary, err
+}
+
+// parse reads the document and records the emitted and skipped entities in the summary
+func (esp *EntityParser) parse(ctx context.Context, reader io.Reader, emitEntity func(*Entity) error, emitContinuation func(*Continuation), summary *ParseSummary) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// line delimited documents are read one line at a time so that each line holds exactly one value
+	if esp.entityErrorHandler != nil || esp.lineDelimited {
+		return esp.parseRawEntities(ctx, reader, emitEntity, emitContinuation, summary)
+	}
+
+	if esp.limits.MaxBytes > 0 {
+		reader = newLimitedReader(reader, esp.limits.MaxBytes)
+	}
 	decoder := json.NewDecoder(reader)
+	// numbers are read as json.Number and converted according to the number mode
+	decoder.UseNumber()
+	state := newParseState(decoder, esp.limits)
+
+	var t json.Token
+	var err error
 
 	// expect start of array
-	t, err := decoder.Token()
+	t, err = state.token()
 	if err != nil {
-		return fmt.Errorf("parsing error: Bad token at start of stream: %w", err)
+		return err
 	}
 
 	if delim, ok := t.(json.Delim); !ok || delim != '[' {
-		return errors.New("parsing error: Expected [ at start of document")
+		return state.errorf(ErrUnexpectedToken, "expected [ at start of document")
 	}
 
 	// decode context object
 	if esp.requireContext {
-		if esp.nsManager == nil {
-			return errors.New("parsing error: Namespace manager required when parsing with context")
-		}
-		context := make(map[string]any)
-		err = decoder.Decode(&context)
+		err = esp.parseContext(state)
 		if err != nil {
-			return fmt.Errorf("parsing error: Unable to decode context: %w", err)
-		}
-
-		if context["id"] == "@context" {
-			if context["namespaces"] != nil {
-				for k, v := range context["namespaces"].(map[string]any) {
-					expansion := v.(string)
-					if esp.lenientNamespaceCheck || strings.HasSuffix(expansion, "/") || strings.HasSuffix(expansion, "#") {
-						esp.nsManager.StorePrefixExpansionMapping(k, v.(string))
-					} else {
-						return fmt.Errorf("expansion %s for prefix %s must end with / or #", expansion, k)
-					}
-				}
-			}
-		} else {
-			return errors.New("first object in array must be a context with id @context")
-		}
-
-		// if a callback func for the parsed context is registered we call it
-		if esp.contextParsedCallback != nil {
-			esp.contextParsedCallback(esp.nsManager.AsContext())
+			return err
 		}
 	}
 
 	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
 		t, err = decoder.Token()
 		if err != nil {
 			if err == io.EOF {
 				break
 			} else {
-				return fmt.Errorf("parsing error: Unable to read next token: %w", err)
+				return state.wrap(fmt.Errorf("unable to read next token: %w", err))
 			}
 		}
 
 		switch v := t.(type) {
 		case json.Delim:
 			if v == '{' {
-				e, err := esp.parseEntity(decoder)
+				state.startEntity()
+				e, err := esp.parseEntity(state)
 				if err != nil {
-					return fmt.Errorf("parsing error: Unable to parse entity: %w", err)
+					return err
 				}
-				if e.ID == "@continuation" {
-					if emitContinuation != nil {
-						continuation := NewContinuation()
-						continuation.Token = e.Properties["token"].(string)
-						emitContinuation(continuation)
-					}
-				} else {
-					err = emitEntity(e)
-					if err != nil {
-						return err
-					}
+				err = esp.emit(state, e, emitEntity, emitContinuation, summary)
+				if err != nil {
+					return err
 				}
 			} else if v == ']' {
 				// done
 				break
+			} else {
+				return state.errorf(ErrUnexpectedToken, "unexpected delimiter %s in entity stream", v)
 			}
 		default:
-			return errors.New("parsing error: unexpected value in entity array")
+			return state.errorf(ErrUnexpectedToken, "unexpected value in entity array")
+		}
+	}
+
+	return nil
+}
+
+// emit passes a parsed entity, or the continuation, to the callbacks of Parse and counts the entity in the summary
+func (esp *EntityParser) emit(state *parseState, e *Entity, emitEntity func(*Entity) error, emitContinuation func(*Continuation), summary *ParseSummary) error {
+	if e.ID == "@continuation" {
+		if emitContinuation != nil {
+			continuation := NewContinuation()
+			continuation.Token, _ = e.Properties["token"].(string)
+			emitContinuation(continuation)
+		}
+		return nil
+	}
+	if err := esp.checkEntityLimit(state, summary); err != nil {
+		return err
+	}
+	summary.EntityCount++
+	return emitEntity(e)
+}
+
+// checkEntityLimit returns a ParseError if one more entity exceeds MaxEntities. The @continuation is not an entity and
+// is not counted.
+func (esp *EntityParser) checkEntityLimit(state *parseState, summary *ParseSummary) error {
+	return state.checkLimit("MaxEntities", esp.limits.MaxEntities, summary.EntityCount+len(summary.Skipped)+1)
+}
+
+// parseContext reads the @context object and stores its namespaces in the namespace manager
+func (esp *EntityParser) parseContext(state *parseState) error {
+	if esp.nsManager == nil {
+		return errors.New("parsing error: Namespace manager required when parsing with context")
+	}
+	contextObject := make(map[string]any)
+	err := state.decoder.Decode(&contextObject)
+	if err != nil {
+		return state.wrap(fmt.Errorf("%w: unable to decode context: %w", ErrBadContext, err))
+	}
+
+	var conflicts []PrefixConflict
+	if contextObject["id"] != "@context" {
+		return state.errorf(ErrBadContext, "first object in array must be a context with id @context")
+	}
+	if contextObject["namespaces"] != nil {
+		namespaces, ok := contextObject["namespaces"].(map[string]any)
+		if !ok {
+			return state.errorf(ErrBadContext, "namespaces must be an object")
+		}
+		// the prefixes are stored in order so that renamed prefixes do not depend on map iteration
+		for _, k := range sortedKeys(namespaces) {
+			expansion, ok := namespaces[k].(string)
+			if !ok {
+				return state.errorf(ErrBadContext, "expansion for prefix %s must be a string", k)
+			}
+			// expansions of non hierarchical IRIs, such as urn:uuid:, end with a colon
+			if esp.lenientNamespaceCheck || strings.HasSuffix(expansion, "/") || strings.HasSuffix(expansion, "#") ||
+				(strings.HasSuffix(expansion, ":") && esp.nsManager.IsFullUri(expansion)) {
+				conflict, err := storePrefixExpansionMappingWithPolicy(esp.nsManager, k, expansion, esp.prefixConflictPolicy)
+				if err != nil {
+					return state.wrap(fmt.Errorf("%w: unable to store namespace mapping: %w", ErrBadContext, err))
+				}
+				if conflict != nil {
+					conflicts = append(conflicts, *conflict)
+					if conflict.ResolvedPrefix != "" && conflict.ResolvedPrefix != k {
+						if state.prefixRenames == nil {
+							state.prefixRenames = make(map[string]string)
+						}
+						state.prefixRenames[k] = conflict.ResolvedPrefix
+					}
+				}
+			} else {
+				return state.errorf(ErrBadContext, "expansion %s for prefix %s must end with / or #", expansion, k)
+			}
 		}
 	}
 
+	// if a callback func for the parsed context is registered we call it
+	if esp.contextParsedCallback != nil {
+		esp.contextParsedCallback(esp.nsManager.AsContext())
+	}
+	if esp.prefixConflicts != nil && len(conflicts) > 0 {
+		esp.prefixConflicts(conflicts)
+	}
 	return nil
 }
 
-func (esp *EntityParser) parseEntity(decoder *json.Decoder) (*Entity, error) {
+func (esp *EntityParser) parseEntity(state *parseState) (*Entity, error) {
+	value, err := esp.parseObject(state)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := value.(*Entity)
+	if !ok {
+		return nil, state.errorf(ErrUnexpectedToken, "value object found when entity expected")
+	}
+	return e, nil
+}
+
+// parseObject parses an entity, or a literal when the object is a value object with an @value key
+func (esp *EntityParser) parseObject(state *parseState) (any, error) {
+	if err := state.enter(); err != nil {
+		return nil, err
+	}
+	defer state.leave()
+
 	e := &Entity{}
 	e.Properties = make(map[string]any)
 	e.References = make(map[string]any)
 	isContinuation := false
+	// the top level entity has an empty path, the ids of embedded entities are not recorded in the state
+	isTopLevel := len(state.path) == 0
+	var literal *valueObject
 	for {
-		t, err := decoder.Token()
+		t, err := state.token()
 		if err != nil {
-			return nil, fmt.Errorf("unable to read token: %w", err)
+			return nil, err
 		}
 
 		switch v := t.(type) {
 		case json.Delim:
 			if v == '}' {
+				if literal != nil && literal.hasValue {
+					if e.ID != "" || len(e.Properties) > 0 || len(e.References) > 0 {
+						return nil, state.errorf(ErrInvalidValue, "value object can not have entity keys")
+					}
+					value, err := esp.parseLiteral(literal)
+					if err != nil {
+						return nil, state.wrap(err)
+					}
+					return value, nil
+				}
 				return e, nil
 			}
 		case string:
 			switch v {
+			case "@value", "@type", "@language":
+				// an entity in the document array is never a value object
+				if isTopLevel {
+					if err = esp.parseUnknownKey(state, e, v); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				if literal == nil {
+					literal = &valueObject{}
+				}
+				val, err := state.token()
+				if err != nil {
+					return nil, err
+				}
+				if _, isDelim := val.(json.Delim); isDelim {
+					return nil, state.errorf(ErrUnexpectedToken, "%s value must be a scalar", v)
+				}
+				switch v {
+				case "@value":
+					literal.value = val
+					literal.hasValue = true
+				case "@type":
+					literal.datatype, _ = val.(string)
+				case "@language":
+					literal.language, _ = val.(string)
+				}
 			case "id":
-				val, err := decoder.Token()
+				val, err := state.token()
 				if err != nil {
-					return nil, fmt.Errorf("unable to read token of id value: %w", err)
+					return nil, err
+				}
+				rawID, ok := val.(string)
+				if !ok {
+					return nil, state.errorf(ErrInvalidValue, "id must be a string")
+				}
+				if isTopLevel {
+					state.entityID = rawID
 				}
 
-				switch val.(string) {
+				switch rawID {
 				case "@continuation":
 					e.ID = "@continuation"
 					isContinuation = true
 				case "@context":
-					return nil, errors.New("context object found when entity expected")
+					return nil, state.errorf(ErrUnexpectedToken, "context object found when entity expected")
 				default:
-					id, err := esp.GetIdentityValue(val.(string))
+					id, err := esp.identityValue(rawID, state.prefixRenames)
 					if err != nil {
-						return nil, err
+						return nil, state.wrap(err)
 					}
 					e.ID = id
 				}
 			case "recorded":
-				val, err := decoder.Token()
+				val, err := state.token()
 				if err != nil {
-					return nil, fmt.Errorf("unable to read token of recorded value: %w", err)
+					return nil, err
+				}
+				number, ok := val.(json.Number)
+				if !ok {
+					return nil, state.errorf(ErrInvalidValue, "recorded value must be a number")
+				}
+				e.Recorded, err = parseUint(number)
+				if err != nil {
+					return nil, state.wrap(fmt.Errorf("%w: unable to parse recorded value: %w", ErrInvalidValue, err))
+				}
+			case "internalId":
+				val, err := state.token()
+				if err != nil {
+					return nil, err
+				}
+				number, ok := val.(json.Number)
+				if !ok {
+					return nil, state.errorf(ErrInvalidValue, "internalId value must be a number")
+				}
+				e.InternalID, err = parseUint(number)
+				if err != nil {
+					return nil, state.wrap(fmt.Errorf("%w: unable to parse internalId value: %w", ErrInvalidValue, err))
 				}
-				e.Recorded = uint64(val.(float64))
 			case "deleted":
-				val, err := decoder.Token()
+				val, err := state.token()
 				if err != nil {
-					return nil, fmt.Errorf("unable to read token of deleted value: %w", err)
+					return nil, err
 				}
-				e.IsDeleted = val.(bool)
+				deleted, ok := val.(bool)
+				if !ok {
+					return nil, state.errorf(ErrInvalidValue, "deleted value must be a boolean")
+				}
+				e.IsDeleted = deleted
 			case "props":
-				e.Properties, err = esp.parseProperties(decoder)
+				state.push("props")
+				e.Properties, err = esp.parseProperties(state)
 				if err != nil {
-					return nil, fmt.Errorf("unable to parse properties: %w", err)
+					return nil, err
 				}
+				state.pop()
 			case "refs":
-				e.References, err = esp.parseReferences(decoder)
+				state.push("refs")
+				e.References, err = esp.parseReferences(state)
 				if err != nil {
-					return nil, fmt.Errorf("unable to parse references %w", err)
+					return nil, err
 				}
+				state.pop()
 			case "token":
 				if !isContinuation {
-					return nil, errors.New("token property found but not a continuation entity")
+					return nil, state.errorf(ErrUnexpectedToken, "token property found but not a continuation entity")
 				}
-				val, err := decoder.Token()
+				val, err := state.token()
 				if err != nil {
-					return nil, fmt.Errorf("unable to read continuation token value: %w", err)
+					return nil, err
 				}
 				e.Properties = make(map[string]any)
 				e.Properties["token"] = val
 			default:
-				// log named property
-				// read value
-				_, err := decoder.Token()
-				if err != nil {
-					return nil, fmt.Errorf("unable to parse value of unknown key: %s %w", v, err)
+				if err = esp.parseUnknownKey(state, e, v); err != nil {
+					return nil, err
 				}
 			}
 		default:
-			return nil, errors.New("unexpected value in entity")
+			return nil, state.errorf(ErrUnexpectedToken, "unexpected value in entity")
+		}
+	}
+}
+
+// parseUnknownKey rejects, skips or preserves the value of a key that is not an entity key, as set by the unknown key
+// mode of the parser
+func (esp *EntityParser) parseUnknownKey(state *parseState, e *Entity, key string) error {
+	if esp.unknownKeyMode == unknownKeysReject {
+		return state.errorf(ErrUnknownKey, "%s", key)
+	}
+	state.push(key)
+	val, err := esp.parseUnknownValue(state, esp.unknownKeyMode == unknownKeysPreserve)
+	if err != nil {
+		return err
+	}
+	state.pop()
+	if esp.unknownKeyMode == unknownKeysPreserve {
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]any)
 		}
+		e.Extensions[key] = val
 	}
+	return nil
 }
 
-func (esp *EntityParser) parseReferences(decoder *json.Decoder) (map[string]any, error) {
+func (esp *EntityParser) parseReferences(state *parseState) (map[string]any, error) {
 	refs := make(map[string]any)
 
-	st, err := decoder.Token()
+	st, err := state.token()
 	if err != nil {
-		return nil, fmt.Errorf("unable to read token at start of references: %w", err)
+		return nil, err
 	}
 
 	if st == nil {
@@ -296,13 +650,13 @@ func (esp *EntityParser) parseReferences(decoder *json.Decoder) (map[string]any,
 	}
 
 	if delim, ok := st.(json.Delim); !ok || delim != '{' {
-		return nil, errors.New("expected { at start of references")
+		return nil, state.errorf(ErrUnexpectedToken, "expected { at start of references")
 	}
 
 	for {
-		t, err := decoder.Token()
+		t, err := state.token()
 		if err != nil {
-			return nil, fmt.Errorf("unable to read token in parse references: %w", err)
+			return nil, err
 		}
 
 		switch v := t.(type) {
@@ -311,28 +665,34 @@ func (esp *EntityParser) parseReferences(decoder *json.Decoder) (map[string]any,
 				return refs, nil
 			}
 		case string:
-			val, err := esp.parseRefValue(decoder)
+			state.push(v)
+			err = state.checkLimit("MaxProperties", state.limits.MaxProperties, len(refs)+1)
 			if err != nil {
-				return nil, fmt.Errorf("unable to parse value of reference key %s", v)
+				return nil, err
 			}
-
-			id, err := esp.GetIdentityValue(v)
+			val, err := esp.parseRefValue(state)
 			if err != nil {
 				return nil, err
 			}
+
+			id, err := esp.identityValue(v, state.prefixRenames)
+			if err != nil {
+				return nil, state.wrap(err)
+			}
+			state.pop()
 			refs[id] = val
 		default:
-			return nil, errors.New("unknown type")
+			return nil, state.errorf(ErrUnexpectedToken, "unexpected key in references")
 		}
 	}
 }
 
-func (esp *EntityParser) parseProperties(decoder *json.Decoder) (map[string]any, error) {
+func (esp *EntityParser) parseProperties(state *parseState) (map[string]any, error) {
 	props := make(map[string]any)
 
-	st, err := decoder.Token()
+	st, err := state.token()
 	if err != nil {
-		return nil, fmt.Errorf("unable to read token of at start of properties: %w ", err)
+		return nil, err
 	}
 
 	if st == nil {
@@ -341,13 +701,13 @@ func (esp *EntityParser) parseProperties(decoder *json.Decoder) (map[string]any,
 	}
 
 	if delim, ok := st.(json.Delim); !ok || delim != '{' {
-		return nil, errors.New("expected { at start of references")
+		return nil, state.errorf(ErrUnexpectedToken, "expected { at start of properties")
 	}
 
 	for {
-		t, err := decoder.Token()
+		t, err := state.token()
 		if err != nil {
-			return nil, fmt.Errorf("unable to read token in parse properties: %w", err)
+			return nil, err
 		}
 
 		switch v := t.(type) {
@@ -356,54 +716,60 @@ func (esp *EntityParser) parseProperties(decoder *json.Decoder) (map[string]any,
 				return props, nil
 			}
 		case string:
-			val, err := esp.parseValue(decoder)
+			state.push(v)
+			err = state.checkLimit("MaxProperties", state.limits.MaxProperties, len(props)+1)
 			if err != nil {
-				return nil, fmt.Errorf("unable to parse property value of key %s err: %w", v, err)
+				return nil, err
+			}
+			val, err := esp.parseValue(state)
+			if err != nil {
+				return nil, err
 			}
 
 			if val != nil {
-				id, err := esp.GetIdentityValue(v)
+				id, err := esp.identityValue(v, state.prefixRenames)
 				if err != nil {
-					return nil, err
+					return nil, state.wrap(err)
 				}
 				props[id] = val
 			}
+			state.pop()
 		default:
-			return nil, errors.New("unknown type")
+			return nil, state.errorf(ErrUnexpectedToken, "unexpected key in properties")
 		}
 	}
 }
 
-func (esp *EntityParser) parseRefValue(decoder *json.Decoder) (any, error) {
+func (esp *EntityParser) parseRefValue(state *parseState) (any, error) {
 	for {
-		t, err := decoder.Token()
+		t, err := state.token()
 		if err != nil {
-			return nil, fmt.Errorf("unable to read token in parse value: %w", err)
+			return nil, err
 		}
 
 		switch v := t.(type) {
 		case json.Delim:
 			if v == '[' {
-				return esp.parseRefArray(decoder)
+				return esp.parseRefArray(state)
 			}
 		case string:
-			id, err := esp.GetIdentityValue(v)
+			id, err := esp.identityValue(v, state.prefixRenames)
 			if err != nil {
-				return nil, err
+				return nil, state.wrap(err)
 			}
 			return id, nil
 		default:
-			return nil, errors.New("unknown token in parse ref value")
+			return nil, state.errorf(ErrUnexpectedToken, "reference value must be a string or an array of strings")
 		}
 	}
 }
 
-func (esp *EntityParser) parseRefArray(decoder *json.Decoder) ([]string, error) {
+func (esp *EntityParser) parseRefArray(state *parseState) ([]string, error) {
 	array := make([]string, 0)
 	for {
-		t, err := decoder.Token()
+		t, err := state.token()
 		if err != nil {
-			return nil, fmt.Errorf("unable to read token in parse ref array: %w", err)
+			return nil, err
 		}
 
 		switch v := t.(type) {
@@ -412,62 +778,82 @@ func (esp *EntityParser) parseRefArray(decoder *json.Decoder) ([]string, error)
 				return array, nil
 			}
 		case string:
-			id, err := esp.GetIdentityValue(v)
-			if err != nil {
+			if err = state.checkLimit("MaxArrayLength", state.limits.MaxArrayLength, len(array)+1); err != nil {
 				return nil, err
 			}
+			id, err := esp.identityValue(v, state.prefixRenames)
+			if err != nil {
+				state.push(strconv.Itoa(len(array)))
+				return nil, state.wrap(err)
+			}
 			array = append(array, id)
 		default:
-			return nil, errors.New("unknown type")
+			state.push(strconv.Itoa(len(array)))
+			return nil, state.errorf(ErrUnexpectedToken, "reference value must be a string")
 		}
 	}
 }
 
-func (esp *EntityParser) parseArray(decoder *json.Decoder) ([]any, error) {
+func (esp *EntityParser) parseArray(state *parseState) ([]any, error) {
+	if err := state.enter(); err != nil {
+		return nil, err
+	}
+	defer state.leave()
+
 	array := make([]any, 0)
 	for {
-		t, err := decoder.Token()
+		t, err := state.token()
 		if err != nil {
-			return nil, fmt.Errorf("unable to read token in parse array: %w", err)
+			return nil, err
 		}
 
+		state.push(strconv.Itoa(len(array)))
+		if t != json.Delim(']') {
+			if err = state.checkLimit("MaxArrayLength", state.limits.MaxArrayLength, len(array)+1); err != nil {
+				return nil, err
+			}
+		}
 		switch v := t.(type) {
 		case json.Delim:
 			switch v {
 			case '{':
-				r, err := esp.parseEntity(decoder)
+				r, err := esp.parseObject(state)
 				if err != nil {
-					return nil, fmt.Errorf("unable to parse array: %w", err)
+					return nil, err
 				}
 				array = append(array, r)
 			case ']':
+				state.pop()
 				return array, nil
 			case '[':
-				r, err := esp.parseArray(decoder)
+				r, err := esp.parseArray(state)
 				if err != nil {
-					return nil, fmt.Errorf("unable to parse array: %w", err)
+					return nil, err
 				}
 				array = append(array, r)
 			}
 		case string:
 			array = append(array, v)
-		case int:
-			array = append(array, v)
-		case float64:
-			array = append(array, v)
+		case json.Number:
+			number, err := esp.parseNumber(v)
+			if err != nil {
+				return nil, state.wrap(fmt.Errorf("%w: %w", ErrInvalidValue, err))
+			}
+			array = append(array, number)
 		case bool:
 			array = append(array, v)
 		default:
-			return nil, errors.New("unknown type")
+			return nil, state.errorf(ErrUnexpectedToken, "unexpected value in array")
 		}
+		state.pop()
 	}
 }
 
-func (esp *EntityParser) parseValue(decoder *json.Decoder) (any, error) {
+func (esp *EntityParser) parseValue(state *parseState) (any, error) {
 	for {
-		t, err := decoder.Token()
+		t, err := state.token()
 		if err != nil {
-			return nil, fmt.Errorf("unable to read token in parse value: %w", err)
+			return nil, err
 		}
 
 		if t == nil {
@@ -479,20 +865,207 @@ func (esp *EntityParser) parseValue(decoder *json.Decoder) (any, error) {
 		case json.Delim:
 			switch v {
 			case '{':
-				return esp.parseEntity(decoder)
+				return esp.parseObject(state)
 			case '[':
-				return esp.parseArray(decoder)
+				return esp.parseArray(state)
 			}
 		case string:
 			return v, nil
-		case int:
-			return v, nil
-		case float64:
-			return v, nil
+		case json.Number:
+			number, err := esp.parseNumber(v)
+			if err != nil {
+				return nil, state.wrap(fmt.Errorf("%w: %w", ErrInvalidValue, err))
+			}
+			return number, nil
 		case bool:
 			return v, nil
 		default:
-			return nil, errors.New("unknown token in parse value")
+			return nil, state.errorf(ErrUnexpectedToken, "unexpected value")
+		}
+	}
+}
+
+// parseUnknownValue reads the value of an unknown key, including nested objects and arrays. The value is only built
+// when it is kept, objects become map[string]any, arrays []any and numbers json.Number.
+func (esp *EntityParser) parseUnknownValue(state *parseState, keep bool) (any, error) {
+	t, err := state.token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, isDelim := t.(json.Delim)
+	if !isDelim {
+		return t, nil
+	}
+	if err := state.enter(); err != nil {
+		return nil, err
+	}
+	defer state.leave()
+
+	switch delim {
+	case '{':
+		var object map[string]any
+		if keep {
+			object = make(map[string]any)
+		}
+		for count := 1; ; count++ {
+			t, err := state.token()
+			if err != nil {
+				return nil, err
+			}
+			if t == json.Delim('}') {
+				return object, nil
+			}
+			key, ok := t.(string)
+			if !ok {
+				return nil, state.errorf(ErrUnexpectedToken, "unexpected key in object")
+			}
+			if err = state.checkLimit("MaxProperties", state.limits.MaxProperties, count); err != nil {
+				return nil, err
+			}
+			val, err := esp.parseUnknownValue(state, keep)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				object[key] = val
+			}
+		}
+	case '[':
+		var array []any
+		if keep {
+			array = make([]any, 0)
+		}
+		for count := 1; ; count++ {
+			if !state.decoder.More() {
+				if _, err := state.token(); err != nil {
+					return nil, err
+				}
+				return array, nil
+			}
+			if err = state.checkLimit("MaxArrayLength", state.limits.MaxArrayLength, count); err != nil {
+				return nil, err
+			}
+			val, err := esp.parseUnknownValue(state, keep)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				array = append(array, val)
+			}
+		}
+	}
+	return nil, state.errorf(ErrUnexpectedToken, "unexpected delimiter %s", delim)
+}
+
+// valueObject holds the keys of a value object while it is parsed
+type valueObject struct {
+	value    json.Token
+	hasValue bool
+	datatype string
+	language string
+}
+
+// parseLiteral returns the property value of a value object. Language tagged strings become LangString, values of
+// the supported xsd datatypes become the matching Go value and values of other datatypes are kept as JsonLdValue.
+func (esp *EntityParser) parseLiteral(literal *valueObject) (any, error) {
+	var lexical string
+	switch v := literal.value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		lexical = v
+	case json.Number:
+		lexical = v.String()
+	case bool:
+		lexical = strconv.FormatBool(v)
+	}
+
+	if literal.language != "" {
+		if _, isString := literal.value.(string); !isString {
+			return nil, fmt.Errorf("%w: language tagged value must be a string", ErrInvalidValue)
+		}
+		return LangString{Value: lexical, Language: literal.language}, nil
+	}
+
+	if literal.datatype == "" {
+		if number, isNumber := literal.value.(json.Number); isNumber {
+			return esp.parseNumber(number)
 		}
+		return literal.value, nil
+	}
+
+	datatype := literal.datatype
+	if esp.nsManager != nil && !esp.nsManager.IsFullUri(datatype) {
+		if fullDatatype, err := esp.nsManager.GetFullURI(datatype); err == nil {
+			datatype = fullDatatype
+		}
+	}
+
+	switch datatype {
+	case xsdString:
+		return lexical, nil
+	case xsdDecimal:
+		if isDecimalLexical(lexical) {
+			return Decimal(lexical), nil
+		}
+	default:
+		value := literalValue(rdfTerm{kind: rdfLiteral, value: lexical, datatype: datatype})
+		if _, isValueObject := value.(JsonLdValue); !isValueObject {
+			return value, nil
+		}
+	}
+	return JsonLdValue{Value: lexical, Type: literal.datatype}, nil
+}
+
+func (esp *EntityParser) parseNumber(number json.Number) (any, error) {
+	switch esp.numberMode {
+	case numberModeJSONNumber:
+		return number, nil
+	case numberModeExact:
+		return exactNumber(number), nil
+	}
+	return number.Float64()
+}
+
+// exactNumber returns the number as the first of int64, uint64, float64 and *big.Float that holds it exactly
+func exactNumber(number json.Number) any {
+	if i, err := strconv.ParseInt(number.String(), 10, 64); err == nil {
+		return i
+	}
+	if u, err := strconv.ParseUint(number.String(), 10, 64); err == nil {
+		return u
+	}
+
+	r, ok := new(big.Rat).SetString(number.String())
+	if !ok {
+		return number
+	}
+	if f, exact := r.Float64(); exact {
+		return f
+	}
+
+	// only a rational with a power of two denominator has an exact binary representation
+	denominator := r.Denom()
+	if uint(denominator.BitLen()-1) != denominator.TrailingZeroBits() {
+		return number
+	}
+	precision := uint(r.Num().BitLen())
+	if precision < 64 {
+		precision = 64
+	}
+	return new(big.Float).SetPrec(precision).SetRat(r)
+}
+
+// parseUint parses a number as uint64 without going through float64 when the number is an integer
+func parseUint(number json.Number) (uint64, error) {
+	u, err := strconv.ParseUint(number.String(), 10, 64)
+	if err == nil {
+		return u, nil
+	}
+	f, floatErr := number.Float64()
+	if floatErr != nil || f < 0 || f != math.Trunc(f) || f >= math.MaxUint64 {
+		return 0, err
 	}
+	return uint64(f), nil
 }
@@ -3,6 +3,12 @@ package egdm
 // basic go test for the parser using standard test setup
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -1427,3 +1433,496 @@ func TestParsePropertyWithArrayValue(t *testing.T) {
 		t.Errorf("unexpected tag values: %v", tags)
 	}
 }
+
+func TestParseNDJSON(t *testing.T) {
+	byteReader := bytes.NewReader([]byte(`{"id":"@context","namespaces":{"ex":"http://example.com/"}}
+{"id":"ex:1","props":{"ex:name":"John Smith"},"refs":{"ex:parent":"ex:2"}}
+{"id":"ex:2","props":{"ex:name":"Jane Smith"}}
+{"id":"@continuation","token":"1234"}
+`))
+
+	nsManager := NewNamespaceContext()
+	parser := NewEntityParser(nsManager).WithNDJSON().WithExpandURIs()
+	ec, err := parser.LoadEntityCollection(byteReader)
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	if len(ec.Entities) != 2 {
+		t.Fatalf("Expected 2 entities, got %d", len(ec.Entities))
+	}
+	if ec.Entities[0].ID != "http://example.com/1" {
+		t.Errorf("Expected entity id to be http://example.com/1, got %s", ec.Entities[0].ID)
+	}
+	if ec.Entities[0].References["http://example.com/parent"] != "http://example.com/2" {
+		t.Errorf("Expected parent reference to be expanded, got %v", ec.Entities[0].References)
+	}
+	if ec.Continuation == nil || ec.Continuation.Token != "1234" {
+		t.Errorf("Expected continuation token 1234, got %v", ec.Continuation)
+	}
+}
+
+func TestParseNDJSONWithoutContext(t *testing.T) {
+	byteReader := bytes.NewReader([]byte(`{"id":"http://example.com/1","props":{"http://example.com/name":"John Smith"}}
+{"id":"http://example.com/2","props":{"http://example.com/name":"Jane Smith"}}`))
+
+	nsManager := NewNamespaceContext()
+	parser := NewEntityParser(nsManager).WithNDJSON().WithNoContext().WithCompressURIs()
+	ec, err := parser.LoadEntityCollection(byteReader)
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	if len(ec.Entities) != 2 {
+		t.Fatalf("Expected 2 entities, got %d", len(ec.Entities))
+	}
+	if ec.Entities[1].ID != "ns0:2" {
+		t.Errorf("Expected entity id to be ns0:2, got %s", ec.Entities[1].ID)
+	}
+}
+
+func TestParseNDJSONRejectsArrayDocument(t *testing.T) {
+	byteReader := bytes.NewReader([]byte(`[{"id":"@context","namespaces":{}}]`))
+
+	parser := NewEntityParser(NewNamespaceContext()).WithNDJSON()
+	_, err := parser.LoadEntityCollection(byteReader)
+	if err == nil {
+		t.Error("Expected error when parsing array document as NDJSON")
+	}
+}
+
+func TestParseNDJSONRejectsSeveralValuesOnOneLine(t *testing.T) {
+	for name, data := range map[string]string{
+		"entities": `{"id":"http://a.com/1"} {"id":"http://a.com/2"}`,
+		"context":  `{"id":"@context","namespaces":{}} {"id":"http://a.com/1"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			parser := NewEntityParser(NewNamespaceContext()).WithNDJSON()
+			if name == "entities" {
+				parser.WithNoContext()
+			}
+			_, err := parser.LoadEntityCollection(strings.NewReader(data))
+			var parseError *ParseError
+			if !errors.Is(err, ErrUnexpectedToken) || !errors.As(err, &parseError) {
+				t.Errorf("expected unexpected token error for several values on one line, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseRoundTripEntityCollectionNDJSON(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	ec := NewEntityCollection(nsManager)
+	for _, id := range []string{"ex:1", "ex:2"} {
+		address := NewEntity()
+		address.SetProperty("ex:street", "123 Main Street")
+		if err := ec.AddEntity(NewEntity().SetID(id).SetProperty("ex:address", address)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	continuation := NewContinuation()
+	continuation.Token = "1234"
+	ec.SetContinuationToken(continuation)
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteEntityGraphNDJSON(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines, got %d: %s", len(lines), buffer.String())
+	}
+
+	parsed, err := NewEntityParser(NewNamespaceContext()).WithNDJSON().LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	if len(parsed.Entities) != 2 {
+		t.Fatalf("Expected 2 entities, got %d", len(parsed.Entities))
+	}
+	address, ok := parsed.Entities[1].Properties["ex:address"].(*Entity)
+	if !ok || address.Properties["ex:street"] != "123 Main Street" {
+		t.Errorf("Expected embedded address entity, got %v", parsed.Entities[1].Properties["ex:address"])
+	}
+	if parsed.Continuation == nil || parsed.Continuation.Token != "1234" {
+		t.Errorf("Expected continuation token 1234, got %v", parsed.Continuation)
+	}
+}
+
+func TestParseRecordedWithoutFloatConversion(t *testing.T) {
+	byteReader := bytes.NewReader([]byte(`
+		[ {"id":"@context","namespaces":{}},
+		  {"id":"http://data.example.com/1","recorded":1730979552787404545,"props":{}}
+		]`))
+
+	ec, err := NewEntityParser(NewNamespaceContext()).LoadEntityCollection(byteReader)
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	if ec.Entities[0].Recorded != 1730979552787404545 {
+		t.Errorf("expected Recorded=1730979552787404545, got %d", ec.Entities[0].Recorded)
+	}
+}
+
+func TestParseNumbersAsJSONNumber(t *testing.T) {
+	byteReader := bytes.NewReader([]byte(`
+		[ {"id":"@context","namespaces":{}},
+		  {"id":"http://data.example.com/1","props":{
+			"http://data.example.com/id":9007199254740993,
+			"http://data.example.com/amounts":[0.1, 12]
+		  }}
+		]`))
+
+	ec, err := NewEntityParser(NewNamespaceContext()).WithJSONNumbers().LoadEntityCollection(byteReader)
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	entity := ec.Entities[0]
+	if entity.Properties["http://data.example.com/id"] != json.Number("9007199254740993") {
+		t.Errorf("expected json.Number, got %T %v", entity.Properties["http://data.example.com/id"], entity.Properties["http://data.example.com/id"])
+	}
+	id, err := entity.GetFirstIntPropertyValue("http://data.example.com/id")
+	if err != nil || id != 9007199254740993 {
+		t.Errorf("expected int 9007199254740993, got %d %v", id, err)
+	}
+	amounts, err := entity.GetFloatPropertyValues("http://data.example.com/amounts")
+	if err != nil || len(amounts) != 2 || amounts[0] != 0.1 || amounts[1] != 12 {
+		t.Errorf("unexpected amounts %v %v", amounts, err)
+	}
+
+	// json.Number values are written back unchanged
+	buffer := bytes.Buffer{}
+	if err := ec.WriteEntityGraphJSON(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buffer.Bytes(), []byte(`9007199254740993`)) || !bytes.Contains(buffer.Bytes(), []byte(`[0.1,12]`)) {
+		t.Errorf("expected numbers to be written unchanged, got %s", buffer.String())
+	}
+}
+
+func TestGetIntPropertyValuesSameForEveryNumberMode(t *testing.T) {
+	document := `
+		[ {"id":"@context","namespaces":{}},
+		  {"id":"http://data.example.com/1","props":{
+			"http://data.example.com/values":[1, 1.5, -7, 2.0, 1e30, 42]
+		  }}
+		]`
+	parsers := map[string]*EntityParser{
+		"float64":       NewEntityParser(NewNamespaceContext()),
+		"json numbers":  NewEntityParser(NewNamespaceContext()).WithJSONNumbers(),
+		"exact numbers": NewEntityParser(NewNamespaceContext()).WithExactNumbers(),
+	}
+	for mode, parser := range parsers {
+		ec, err := parser.LoadEntityCollection(bytes.NewReader([]byte(document)))
+		if err != nil {
+			t.Fatalf("%s: error parsing entity collection: %s", mode, err)
+		}
+		values, err := ec.Entities[0].GetIntPropertyValues("http://data.example.com/values")
+		if err != nil {
+			t.Fatalf("%s: %s", mode, err)
+		}
+		if len(values) != 4 || values[0] != 1 || values[1] != -7 || values[2] != 2 || values[3] != 42 {
+			t.Errorf("%s: expected [1 -7 2 42], got %v", mode, values)
+		}
+	}
+}
+
+func TestParseExactNumbers(t *testing.T) {
+	byteReader := bytes.NewReader([]byte(`
+		[ {"id":"@context","namespaces":{}},
+		  {"id":"http://data.example.com/1","props":{
+			"http://data.example.com/int":-9007199254740993,
+			"http://data.example.com/uint":18446744073709551615,
+			"http://data.example.com/float":1.5,
+			"http://data.example.com/big":1180591620717411303425,
+			"http://data.example.com/decimal":0.1
+		  }}
+		]`))
+
+	ec, err := NewEntityParser(NewNamespaceContext()).WithExactNumbers().LoadEntityCollection(byteReader)
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	props := ec.Entities[0].Properties
+	if props["http://data.example.com/int"] != int64(-9007199254740993) {
+		t.Errorf("expected int64, got %T %v", props["http://data.example.com/int"], props["http://data.example.com/int"])
+	}
+	if props["http://data.example.com/uint"] != uint64(18446744073709551615) {
+		t.Errorf("expected uint64, got %T %v", props["http://data.example.com/uint"], props["http://data.example.com/uint"])
+	}
+	if props["http://data.example.com/float"] != 1.5 {
+		t.Errorf("expected float64, got %T %v", props["http://data.example.com/float"], props["http://data.example.com/float"])
+	}
+	bigValue, ok := props["http://data.example.com/big"].(*big.Float)
+	if !ok || bigValue.Text('f', 0) != "1180591620717411303425" {
+		t.Errorf("expected exact *big.Float, got %T %v", props["http://data.example.com/big"], props["http://data.example.com/big"])
+	}
+	if props["http://data.example.com/decimal"] != json.Number("0.1") {
+		t.Errorf("expected json.Number for inexact value, got %T %v", props["http://data.example.com/decimal"], props["http://data.example.com/decimal"])
+	}
+
+	value, err := ec.Entities[0].GetFirstIntPropertyValue("http://data.example.com/int")
+	if err != nil || value != -9007199254740993 {
+		t.Errorf("expected int -9007199254740993, got %d %v", value, err)
+	}
+	floatValue, err := ec.Entities[0].GetFirstFloatPropertyValue("http://data.example.com/big")
+	if err != nil || floatValue != 1180591620717411303425 {
+		t.Errorf("expected float 1180591620717411303425, got %f %v", floatValue, err)
+	}
+}
+
+func TestParseNonHTTPIdentifiers(t *testing.T) {
+	data := []byte(`
+		[ {"id":"@context","namespaces":{"ex":"http://data.example.com/","uuid":"urn:uuid:"}},
+		  {"id":"urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66",
+		   "props":{"ex:email":"john@example.com"},
+		   "refs":{"ex:contact":"mailto:john@example.com","ex:owner":"did:example:123456789abcdefghi"}}
+		]`)
+
+	// the identifiers are kept as they are without a mapping for urn, mailto or did
+	ec, err := NewEntityParser(NewNamespaceContext()).LoadEntityCollection(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	entity := ec.Entities[0]
+	if entity.ID != "urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66" {
+		t.Errorf("unexpected id %s", entity.ID)
+	}
+	if entity.References["ex:contact"] != "mailto:john@example.com" || entity.References["ex:owner"] != "did:example:123456789abcdefghi" {
+		t.Errorf("unexpected references %v", entity.References)
+	}
+
+	ec, err = NewEntityParser(NewNamespaceContext()).WithExpandURIs().LoadEntityCollection(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	if ec.Entities[0].ID != "urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66" || ec.Entities[0].References["http://data.example.com/contact"] != "mailto:john@example.com" {
+		t.Errorf("unexpected expanded entity %v %v", ec.Entities[0].ID, ec.Entities[0].References)
+	}
+
+	// when compressing, IRIs without a '/' or '#' use a declared prefix or are kept as they are
+	ec, err = NewEntityParser(NewNamespaceContext()).WithCompressURIs().LoadEntityCollection(bytes.NewReader([]byte(`
+		[ {"id":"@context","namespaces":{"uuid":"urn:uuid:"}},
+		  {"id":"urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66","refs":{"http://data.example.com/contact":"mailto:john@example.com"}}
+		]`)))
+	if err != nil {
+		t.Fatalf("Error parsing entity collection: %s", err)
+	}
+	if ec.Entities[0].ID != "uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66" || ec.Entities[0].References["ns1:contact"] != "mailto:john@example.com" {
+		t.Errorf("unexpected compressed entity %v %v", ec.Entities[0].ID, ec.Entities[0].References)
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	data := `[
+		{"id":"@context","namespaces":{"ex":"http://example.com/"}},
+		{"id":"ex:1","props":{"ex:name":"one"}},
+		{"id":"ex:2","props":{"ex:address":{"props":{"ex:street":"main","bad:city":"x"}}}}
+	]`
+
+	_, err := NewEntityParser(NewNamespaceContext()).LoadEntityCollection(strings.NewReader(data))
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if !errors.Is(err, ErrMissingPrefix) {
+		t.Errorf("expected missing prefix error, got %v", err)
+	}
+	if parseError.EntityIndex != 1 || parseError.EntityID != "ex:2" || parseError.Path != "props/ex:address/props/bad:city" {
+		t.Errorf("unexpected position %+v", parseError)
+	}
+	if offset := strings.Index(data, `"x"`) + 3; parseError.Offset != int64(offset) {
+		t.Errorf("expected offset %d, got %d", offset, parseError.Offset)
+	}
+}
+
+func TestParseErrorKinds(t *testing.T) {
+	for name, test := range map[string]struct {
+		data string
+		kind error
+		path string
+	}{
+		"missing context":  {`[{"id":"ex:1"}]`, ErrBadContext, ""},
+		"bad expansion":    {`[{"id":"@context","namespaces":{"ex":"http://example.com"}}]`, ErrBadContext, ""},
+		"not an array":     {`{"id":"@context"}`, ErrUnexpectedToken, ""},
+		"ref value":        {`[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1","refs":{"ex:knows":["ex:2",1]}}]`, ErrUnexpectedToken, "refs/ex:knows/1"},
+		"array value":      {`[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1","props":{"ex:tags":["a",{"id":"ex:3","props":{"zz:x":1}}]}}]`, ErrMissingPrefix, "props/ex:tags/1/props/zz:x"},
+		"recorded":         {`[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1","recorded":"1"}]`, ErrInvalidValue, ""},
+		"language literal": {`[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1","props":{"ex:n":{"@value":1,"@language":"en"}}}]`, ErrInvalidValue, "props/ex:n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewEntityParser(NewNamespaceContext()).LoadEntityCollection(strings.NewReader(test.data))
+			var parseError *ParseError
+			if !errors.As(err, &parseError) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if !errors.Is(err, test.kind) {
+				t.Errorf("expected %v, got %v", test.kind, err)
+			}
+			if parseError.Path != test.path {
+				t.Errorf("expected path %s, got %s", test.path, parseError.Path)
+			}
+		})
+	}
+}
+
+func TestParseErrorWrapsPrefixConflict(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.org/")
+	_, err := NewEntityParser(nsManager).WithPrefixConflictPolicy(PrefixConflictError).
+		LoadEntityCollection(strings.NewReader(`[{"id":"@context","namespaces":{"ex":"http://example.com/"}}]`))
+	if !errors.Is(err, ErrBadContext) || !errors.Is(err, ErrPrefixConflict) {
+		t.Errorf("expected bad context and prefix conflict, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "parsing error") {
+		t.Errorf("unexpected message %s", err.Error())
+	}
+}
+
+func TestParseContextCancellation(t *testing.T) {
+	data := `[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1"},{"id":"ex:2"},{"id":"ex:3"}]`
+
+	for name, parser := range map[string]*EntityParser{
+		"strict":  NewEntityParser(NewNamespaceContext()),
+		"lenient": NewEntityParser(NewNamespaceContext()).WithEntityErrorHandler(func(int, json.RawMessage, error) error { return nil }),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			count := 0
+			err := parser.ParseContext(ctx, strings.NewReader(data), func(e *Entity) error {
+				count++
+				if count == 2 {
+					cancel()
+				}
+				return nil
+			}, nil)
+			if !errors.Is(err, context.Canceled) || count != 2 {
+				t.Errorf("expected parsing to stop after 2 entities, got %v after %d", err, count)
+			}
+
+			if _, err = parser.LoadEntityCollectionContext(ctx, strings.NewReader(data)); err != context.Canceled {
+				t.Errorf("expected cancelled context to fail, got %v", err)
+			}
+		})
+	}
+
+	var parsers []ContextParser
+	parsers = append(parsers, NewEntityParser(NewNamespaceContext()), NewJsonLDParser(NewNamespaceContext()), NewRDFParser(NewNamespaceContext()))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, parser := range parsers {
+		if _, err := parser.LoadEntityCollectionContext(ctx, strings.NewReader(data)); err != context.Canceled {
+			t.Errorf("expected %T to return the context error, got %v", parser, err)
+		}
+	}
+}
+
+func TestParseUnknownEntityKeys(t *testing.T) {
+	data := `[{"id":"@context","namespaces":{"ex":"http://example.com/"}},
+		{"id":"ex:1","meta":{"source":"feed","tags":["a",{"b":[1,2.5]}]},"flag":true,"props":{"ex:name":"one"},"internalId":7},
+		{"id":"ex:2","nothing":null,"props":{"ex:name":"two"}}]`
+
+	// unknown values are skipped, including nested objects and arrays
+	ec, err := NewEntityParser(NewNamespaceContext()).LoadEntityCollection(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ec.Entities) != 2 || ec.Entities[0].Properties["ex:name"] != "one" || ec.Entities[0].InternalID != 7 {
+		t.Errorf("unexpected entities %v", ec.Entities)
+	}
+	if ec.Entities[0].Extensions != nil {
+		t.Errorf("expected unknown keys not to be kept, got %v", ec.Entities[0].Extensions)
+	}
+
+	_, err = NewEntityParser(NewNamespaceContext()).WithStrictKeys().
+		LoadEntityCollection(strings.NewReader(`[{"id":"@context","namespaces":{"ex":"http://example.com/"}},{"id":"ex:1","prop":{"ex:name":"one"}}]`))
+	var parseError *ParseError
+	if !errors.Is(err, ErrUnknownKey) || !errors.As(err, &parseError) || parseError.EntityID != "ex:1" {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+
+	ec, err = NewEntityParser(NewNamespaceContext()).WithPreservedKeys().LoadEntityCollection(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	extensions := ec.Entities[0].Extensions
+	meta, _ := extensions["meta"].(map[string]any)
+	if extensions["flag"] != true || meta["source"] != "feed" || len(meta["tags"].([]any)) != 2 {
+		t.Errorf("unexpected extensions %v", extensions)
+	}
+	if value, found := ec.Entities[1].Extensions["nothing"]; !found || value != nil {
+		t.Errorf("expected null extension to be kept")
+	}
+
+	// the extensions are written back out
+	buffer := bytes.Buffer{}
+	if err = ec.WriteEntityGraphJSON(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), `"flag":true,"meta":{"source":"feed","tags":["a",{"b":[1,2.5]}]}}`) {
+		t.Errorf("expected extensions in output, got %s", buffer.String())
+	}
+	result, err := NewEntityParser(NewNamespaceContext()).WithPreservedKeys().LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Entities[0].Extensions, extensions) {
+		t.Errorf("expected extensions to round trip, got %v", result.Entities[0].Extensions)
+	}
+}
+
+func TestParseValueObjectKeysOnEntity(t *testing.T) {
+	data := `[{"id":"@context","namespaces":{}},{"id":"http://ex/1","@type":"x","@value":1,"props":{"http://ex/name":{"@value":"one","@language":"en"}}}]`
+
+	_, err := NewEntityParser(NewNamespaceContext()).WithStrictKeys().LoadEntityCollection(strings.NewReader(data))
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected unknown key error for value object keys on an entity, got %v", err)
+	}
+
+	// value objects are still read where a property value is expected
+	ec, err := NewEntityParser(NewNamespaceContext()).WithPreservedKeys().LoadEntityCollection(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity := ec.Entities[0]
+	if entity.ID != "http://ex/1" || entity.Extensions["@type"] != "x" || entity.Extensions["@value"] != json.Number("1") {
+		t.Errorf("expected value object keys to be preserved as extensions, got %+v", entity)
+	}
+	if entity.Properties["http://ex/name"] != (LangString{Value: "one", Language: "en"}) {
+		t.Errorf("unexpected property value %v", entity.Properties["http://ex/name"])
+	}
+
+	ec, err = NewEntityParser(NewNamespaceContext()).LoadEntityCollection(strings.NewReader(data))
+	if err != nil || ec.Entities[0].ID != "http://ex/1" || ec.Entities[0].Extensions != nil {
+		t.Errorf("expected value object keys on an entity to be skipped, got %v %v", ec, err)
+	}
+}
+
+func TestMarshalEntityWithoutExtensions(t *testing.T) {
+	entity := NewEntity().SetID("ex:1").SetProperty("ex:name", "one")
+	data, err := json.Marshal(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"id":"ex:1","refs":{},"props":{"ex:name":"one"}}` {
+		t.Errorf("unexpected json %s", data)
+	}
+
+	entity.Extensions = map[string]any{"id": "ignored", "meta": 1}
+	data, _ = json.Marshal(entity)
+	if string(data) != `{"id":"ex:1","refs":{},"props":{"ex:name":"one"},"meta":1}` {
+		t.Errorf("unexpected json %s", data)
+	}
+
+	// values are marshalled with their extensions too
+	data, _ = json.Marshal(*entity)
+	if string(data) != `{"id":"ex:1","refs":{},"props":{"ex:name":"one"},"meta":1}` {
+		t.Errorf("unexpected json for entity value %s", data)
+	}
+	data, _ = json.Marshal([]Entity{*entity})
+	if string(data) != `[{"id":"ex:1","refs":{},"props":{"ex:name":"one"},"meta":1}]` {
+		t.Errorf("unexpected json for entity slice %s", data)
+	}
+}
@@ -0,0 +1,105 @@
+package egdm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PrefixConflictPolicy decides what happens when a prefix that is already bound to an expansion is stored with a
+// different expansion
+type PrefixConflictPolicy int
+
+const (
+	// PrefixConflictOverwrite rebinds the prefix to the new expansion, this is the behaviour of StorePrefixExpansionMapping
+	PrefixConflictOverwrite PrefixConflictPolicy = iota
+	// PrefixConflictError rejects the new expansion with an error wrapping ErrPrefixConflict
+	PrefixConflictError
+	// PrefixConflictKeepFirst keeps the existing binding and ignores the new expansion
+	PrefixConflictKeepFirst
+	// PrefixConflictRename binds the new expansion to a fresh prefix, or to the prefix it is already bound to
+	PrefixConflictRename
+)
+
+// ErrPrefixConflict is wrapped by the error returned when a conflict is found with the PrefixConflictError policy
+var ErrPrefixConflict = errors.New("prefix conflict")
+
+// PrefixConflict describes a prefix that was stored with a different expansion than the one it was bound to
+type PrefixConflict struct {
+	Prefix            string
+	Expansion         string
+	ExistingExpansion string
+	// ResolvedPrefix is the prefix the new expansion is bound to after the conflict was resolved. It is empty
+	// when the new expansion was not stored.
+	ResolvedPrefix string
+}
+
+// PrefixConflictResolver is implemented by namespace managers that store mappings with a conflict policy
+type PrefixConflictResolver interface {
+	StorePrefixExpansionMappingWithPolicy(prefix string, expansion string, policy PrefixConflictPolicy) (*PrefixConflict, error)
+}
+
+// storePrefixExpansionMappingWithPolicy stores the mapping with the policy. Namespace managers that implement
+// PrefixConflictResolver resolve the conflict themselves, for other managers the policy is applied through the
+// NamespaceManager interface.
+func storePrefixExpansionMappingWithPolicy(nsManager NamespaceManager, prefix string, expansion string, policy PrefixConflictPolicy) (*PrefixConflict, error) {
+	if resolver, ok := nsManager.(PrefixConflictResolver); ok {
+		return resolver.StorePrefixExpansionMappingWithPolicy(prefix, expansion, policy)
+	}
+
+	existing, err := nsManager.GetNamespaceExpansionForPrefix(prefix)
+	if err != nil || existing == expansion {
+		nsManager.StorePrefixExpansionMapping(prefix, expansion)
+		return nil, nil
+	}
+
+	conflict := &PrefixConflict{Prefix: prefix, Expansion: expansion, ExistingExpansion: existing}
+	switch policy {
+	case PrefixConflictError:
+		return conflict, newPrefixConflictError(conflict)
+	case PrefixConflictKeepFirst:
+		return conflict, nil
+	case PrefixConflictRename:
+		if bound, found := boundPrefixForExpansion(nsManager, expansion); found {
+			conflict.ResolvedPrefix = bound
+			return conflict, nil
+		}
+		conflict.ResolvedPrefix = freshPrefix(prefix, nsManager.DoesExpansionExistForPrefix)
+		nsManager.StorePrefixExpansionMapping(conflict.ResolvedPrefix, expansion)
+		return conflict, nil
+	}
+
+	nsManager.StorePrefixExpansionMapping(prefix, expansion)
+	conflict.ResolvedPrefix = prefix
+	return conflict, nil
+}
+
+// boundPrefixForExpansion returns the prefix the expansion is bound to. The reverse mapping is checked against the
+// prefix, which may have been rebound to another expansion since.
+func boundPrefixForExpansion(nsManager NamespaceManager, expansion string) (string, bool) {
+	bound, err := nsManager.GetPrefixForExpansion(expansion)
+	if err != nil {
+		return "", false
+	}
+	if boundExpansion, err := nsManager.GetNamespaceExpansionForPrefix(bound); err != nil || boundExpansion != expansion {
+		return "", false
+	}
+	return bound, true
+}
+
+func newPrefixConflictError(conflict *PrefixConflict) error {
+	return fmt.Errorf("%w: prefix %s is bound to %s and can not be bound to %s", ErrPrefixConflict, conflict.Prefix, conflict.ExistingExpansion, conflict.Expansion)
+}
+
+// freshPrefix returns the prefix followed by the first number that gives an unused prefix. The default namespace
+// prefix is renamed to a generated ns prefix.
+func freshPrefix(prefix string, isUsed func(prefix string) bool) string {
+	if prefix == "_" {
+		prefix = "ns"
+	}
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s%d", prefix, i)
+		if !isUsed(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package egdm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// plainNamespaceManager hides the PrefixConflictResolver implementation of the wrapped manager
+type plainNamespaceManager struct {
+	NamespaceManager
+}
+
+func TestStorePrefixExpansionMappingRebindKeepsMappingsConsistent(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.org/")
+
+	if _, err := nsManager.GetPrefixForExpansion("http://example.com/"); err == nil {
+		t.Errorf("expected the previous expansion to be unbound")
+	}
+	if prefix, _ := nsManager.GetPrefixForExpansion("http://example.org/"); prefix != "ex" {
+		t.Errorf("expected ex, got %s", prefix)
+	}
+
+	// a previous expansion with another prefix stays bound to that prefix
+	nsManager.StorePrefixExpansionMapping("alias", "http://example.org/")
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	nsManager.StorePrefixExpansionMapping("alias", "http://example.net/")
+	nsManager.StorePrefixExpansionMapping("other", "http://example.org/")
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.net/")
+	if prefix, _ := nsManager.GetPrefixForExpansion("http://example.org/"); prefix != "other" {
+		t.Errorf("expected other, got %s", prefix)
+	}
+	if id, _ := nsManager.GetPrefixedIdentifier("http://example.org/1"); id != "other:1" {
+		t.Errorf("expected other:1, got %s", id)
+	}
+}
+
+func TestStorePrefixExpansionMappingWithPolicy(t *testing.T) {
+	for name, nsManager := range map[string]func() NamespaceManager{
+		"context": func() NamespaceManager { return NewNamespaceContext() },
+		"plain":   func() NamespaceManager { return plainNamespaceManager{NewNamespaceContext()} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			ns := nsManager()
+			ns.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+			conflict, err := storePrefixExpansionMappingWithPolicy(ns, "ex", "http://example.com/", PrefixConflictError)
+			if conflict != nil || err != nil {
+				t.Errorf("expected no conflict for the same expansion, got %v %v", conflict, err)
+			}
+
+			_, err = storePrefixExpansionMappingWithPolicy(ns, "ex", "http://example.org/", PrefixConflictError)
+			if !errors.Is(err, ErrPrefixConflict) {
+				t.Errorf("expected prefix conflict error, got %v", err)
+			}
+
+			conflict, err = storePrefixExpansionMappingWithPolicy(ns, "ex", "http://example.org/", PrefixConflictKeepFirst)
+			if err != nil || conflict == nil || conflict.ResolvedPrefix != "" || conflict.ExistingExpansion != "http://example.com/" {
+				t.Errorf("unexpected keep first conflict %+v %v", conflict, err)
+			}
+			if expansion, _ := ns.GetNamespaceExpansionForPrefix("ex"); expansion != "http://example.com/" {
+				t.Errorf("expected first expansion to be kept, got %s", expansion)
+			}
+
+			conflict, err = storePrefixExpansionMappingWithPolicy(ns, "ex", "http://example.org/", PrefixConflictRename)
+			if err != nil || conflict == nil || conflict.ResolvedPrefix != "ex1" {
+				t.Errorf("unexpected rename conflict %+v %v", conflict, err)
+			}
+			if expansion, _ := ns.GetNamespaceExpansionForPrefix("ex1"); expansion != "http://example.org/" {
+				t.Errorf("expected renamed prefix to be bound, got %s", expansion)
+			}
+
+			// an expansion that is already bound keeps its prefix
+			ns.StorePrefixExpansionMapping("net", "http://example.net/")
+			conflict, err = storePrefixExpansionMappingWithPolicy(ns, "ex", "http://example.net/", PrefixConflictRename)
+			if err != nil || conflict == nil || conflict.ResolvedPrefix != "net" {
+				t.Errorf("unexpected rename conflict %+v %v", conflict, err)
+			}
+
+			conflict, err = storePrefixExpansionMappingWithPolicy(ns, "ex", "http://example.org/", PrefixConflictOverwrite)
+			if err != nil || conflict == nil || conflict.ResolvedPrefix != "ex" {
+				t.Errorf("unexpected overwrite conflict %+v %v", conflict, err)
+			}
+			if expansion, _ := ns.GetNamespaceExpansionForPrefix("ex"); expansion != "http://example.org/" {
+				t.Errorf("expected expansion to be overwritten, got %s", expansion)
+			}
+		})
+	}
+}
+
+func TestParseWithPrefixConflictPolicy(t *testing.T) {
+	data := []byte(`
+		[ {"id":"@context","namespaces":{"ex":"http://example.org/"}},
+		  {"id":"ex:1","refs":{"ex:knows":"ex:2"}}
+		]`)
+
+	newNamespaceManager := func() NamespaceManager {
+		nsManager := NewNamespaceContext()
+		nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+		return nsManager
+	}
+
+	_, err := NewEntityParser(newNamespaceManager()).WithPrefixConflictPolicy(PrefixConflictError).LoadEntityCollection(bytes.NewReader(data))
+	if !errors.Is(err, ErrPrefixConflict) {
+		t.Errorf("expected prefix conflict error, got %v", err)
+	}
+
+	var conflicts []PrefixConflict
+	ec, err := NewEntityParser(newNamespaceManager()).
+		WithPrefixConflictPolicy(PrefixConflictRename).
+		WithPrefixConflictCallback(func(c []PrefixConflict) { conflicts = c }).
+		LoadEntityCollection(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Prefix != "ex" || conflicts[0].ResolvedPrefix != "ex1" {
+		t.Errorf("unexpected conflicts %+v", conflicts)
+	}
+	// the identifiers of the document are rewritten to the renamed prefix
+	entity := ec.Entities[0]
+	if entity.ID != "ex1:1" || entity.References["ex1:knows"] != "ex1:2" {
+		t.Errorf("unexpected entity %s %v", entity.ID, entity.References)
+	}
+	fullURI, _ := ec.NamespaceManager.GetFullURI(entity.ID)
+	if fullURI != "http://example.org/1" {
+		t.Errorf("expected http://example.org/1, got %s", fullURI)
+	}
+
+	ec, err = NewEntityParser(newNamespaceManager()).WithPrefixConflictPolicy(PrefixConflictRename).WithExpandURIs().LoadEntityCollection(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ec.Entities[0].ID != "http://example.org/1" {
+		t.Errorf("expected http://example.org/1, got %s", ec.Entities[0].ID)
+	}
+}
+
+func TestParseWithPrefixConflictPolicyConcurrently(t *testing.T) {
+	for name, lenient := range map[string]bool{"array": false, "lenient": true} {
+		parser := NewEntityParser(NewNamespaceContext()).WithExpandURIs().WithPrefixConflictPolicy(PrefixConflictRename)
+		if lenient {
+			parser = parser.WithEntityErrorHandler(func(index int, raw json.RawMessage, err error) error { return err })
+		}
+
+		// every document binds ex to its own expansion, so the prefix is renamed in all but one of them
+		wg := sync.WaitGroup{}
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					document := fmt.Sprintf(`[{"id":"@context","namespaces":{"ex":"http://example.com/%d/"}},{"id":"ex:%d"}]`, w, i)
+					ec, err := parser.LoadEntityCollection(strings.NewReader(document))
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if expected := fmt.Sprintf("http://example.com/%d/%d", w, i); ec.Entities[0].ID != expected {
+						t.Errorf("%s: expected %s, got %s", name, expected, ec.Entities[0].ID)
+						return
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+	}
+}
+
+func TestParseJSONLDWithPrefixConflictPolicy(t *testing.T) {
+	data := []byte(`{"@context":{"ex":"http://example.org/"},"@id":"ex:1","ex:knows":{"@id":"ex:2"}}`)
+
+	newNamespaceManager := func() NamespaceManager {
+		nsManager := NewNamespaceContext()
+		nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+		return nsManager
+	}
+
+	_, err := NewJsonLDParser(newNamespaceManager()).WithPrefixConflictPolicy(PrefixConflictError).LoadEntityCollection(bytes.NewReader(data))
+	if !errors.Is(err, ErrPrefixConflict) {
+		t.Errorf("expected prefix conflict error, got %v", err)
+	}
+
+	nsManager := newNamespaceManager()
+	ec, err := NewJsonLDParser(nsManager).WithPrefixConflictPolicy(PrefixConflictKeepFirst).LoadEntityCollection(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expansion, _ := nsManager.GetNamespaceExpansionForPrefix("ex"); expansion != "http://example.com/" {
+		t.Errorf("expected first expansion to be kept, got %s", expansion)
+	}
+
+	// the identifiers of the document are rewritten to the renamed prefix
+	ec, err = NewJsonLDParser(newNamespaceManager()).WithPrefixConflictPolicy(PrefixConflictRename).LoadEntityCollection(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity := ec.Entities[0]
+	if entity.ID != "ex1:1" || entity.References["ex1:knows"] != "ex1:2" {
+		t.Errorf("unexpected entity %s %v", entity.ID, entity.References)
+	}
+	if fullURI, _ := ec.NamespaceManager.GetFullURI(entity.ID); fullURI != "http://example.org/1" {
+		t.Errorf("expected http://example.org/1, got %s", fullURI)
+	}
+}
+
+func TestParseTurtleWithPrefixConflictPolicy(t *testing.T) {
+	data := []byte(`@prefix ex: <http://example.org/> .
+ex:1 ex:knows ex:2 .
+`)
+
+	newNamespaceManager := func() NamespaceManager {
+		nsManager := NewNamespaceContext()
+		nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+		return nsManager
+	}
+
+	_, err := NewRDFParser(newNamespaceManager()).WithPrefixConflictPolicy(PrefixConflictError).LoadEntityCollection(bytes.NewReader(data))
+	if !errors.Is(err, ErrPrefixConflict) {
+		t.Errorf("expected prefix conflict error, got %v", err)
+	}
+
+	// the prefixed names of the document are read with the declared expansion
+	nsManager := newNamespaceManager()
+	ec, err := NewRDFParser(nsManager).WithPrefixConflictPolicy(PrefixConflictKeepFirst).LoadEntityCollection(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ec.Entities[0].ID != "http://example.org/1" {
+		t.Errorf("expected http://example.org/1, got %s", ec.Entities[0].ID)
+	}
+	if expansion, _ := nsManager.GetNamespaceExpansionForPrefix("ex"); expansion != "http://example.com/" {
+		t.Errorf("expected first expansion to be kept, got %s", expansion)
+	}
+
+	ec, err = NewRDFParser(newNamespaceManager()).WithPrefixConflictPolicy(PrefixConflictRename).WithCompressURIs().LoadEntityCollection(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ec.Entities[0].ID != "ex1:1" || ec.Entities[0].References["ex1:knows"] != "ex1:2" {
+		t.Errorf("unexpected entity %s %v", ec.Entities[0].ID, ec.Entities[0].References)
+	}
+}
+
+func TestEntityCollectionStorePrefixExpansionMapping(t *testing.T) {
+	ec := NewEntityCollection(nil)
+	ec.SetPrefixConflictPolicy(PrefixConflictKeepFirst)
+	if _, err := ec.StorePrefixExpansionMapping("ex", "http://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	conflict, err := ec.StorePrefixExpansionMapping("ex", "http://example.org/")
+	if err != nil || conflict == nil {
+		t.Fatalf("expected conflict, got %v %v", conflict, err)
+	}
+	if expansion, _ := ec.NamespaceManager.GetNamespaceExpansionForPrefix("ex"); expansion != "http://example.com/" {
+		t.Errorf("expected first expansion to be kept, got %s", expansion)
+	}
+}
@@ -0,0 +1,866 @@
+package egdm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RDFParser reads N-Triples and Turtle and groups the triples by subject into entities. IRI objects become references,
+// literals become properties typed from their datatype and blank node objects become embedded entities. Prefixes
+// declared in the document are stored in the namespace manager, the empty prefix is stored as the default namespace.
+// Turtle collections are not supported.
+type RDFParser struct {
+	nsManager            NamespaceManager
+	compressURIs         bool
+	baseURI              string
+	prefixConflictPolicy PrefixConflictPolicy
+}
+
+func NewRDFParser(nsManager NamespaceManager) *RDFParser {
+	rp := &RDFParser{}
+	// default to inbuilt namespace manager if not defined
+	if nsManager == nil {
+		nsManager = NewNamespaceContext()
+	}
+	rp.nsManager = nsManager
+	return rp
+}
+
+// WithCompressURIs configures the parser to write identifiers as prefixed identifiers, asserting new prefixes as needed.
+// IRIs without a '/' or '#', such as urn:uuid identifiers, are kept as they are unless a declared prefix matches them.
+func (rp *RDFParser) WithCompressURIs() *RDFParser {
+	rp.compressURIs = true
+	return rp
+}
+
+// WithBaseURI sets the URI that relative IRIs are resolved against until the document declares a base
+func (rp *RDFParser) WithBaseURI(baseURI string) *RDFParser {
+	rp.baseURI = baseURI
+	return rp
+}
+
+// WithPrefixConflictPolicy sets the policy used when a prefix declared in the document is already bound to a different
+// expansion in the namespace manager. The prefixed names of the document are always read with the declared expansions.
+func (rp *RDFParser) WithPrefixConflictPolicy(policy PrefixConflictPolicy) *RDFParser {
+	rp.prefixConflictPolicy = policy
+	return rp
+}
+
+func (rp *RDFParser) GetNamespaceManager() NamespaceManager {
+	return rp.nsManager
+}
+
+func (rp *RDFParser) LoadEntityCollection(reader io.Reader) (*EntityCollection, error) {
+	return rp.LoadEntityCollectionContext(context.Background(), reader)
+}
+
+// LoadEntityCollectionContext is LoadEntityCollection but returns ctx.Err() when the context is done
+func (rp *RDFParser) LoadEntityCollectionContext(ctx context.Context, reader io.Reader) (*EntityCollection, error) {
+	ec := NewEntityCollection(rp.nsManager)
+	err := rp.ParseContext(ctx, reader, func(e *Entity) error {
+		return ec.AddEntity(e)
+	}, func(c *Continuation) {
+		ec.SetContinuationToken(c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ec, nil
+}
+
+// ParseContext is Parse but checks the context between entities and returns ctx.Err() when it is done. The document
+// is read before the first entity is emitted.
+func (rp *RDFParser) ParseContext(ctx context.Context, reader io.Reader, emitEntity func(*Entity) error, emitContinuation func(*Continuation)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return rp.Parse(reader, emitWhileNotDone(ctx, emitEntity), emitContinuation)
+}
+
+// Parse reads the whole document and emits one entity per subject in the order the subjects first appear.
+// Blank nodes that are not the object of any triple are emitted as entities without an identity.
+// RDF has no continuation tokens so emitContinuation is never called.
+func (rp *RDFParser) Parse(reader io.Reader, emitEntity func(*Entity) error, emitContinuation func(*Continuation)) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("parsing error: unable to read document: %w", err)
+	}
+
+	tp := &turtleParser{input: []rune(string(data)), line: 1, nsManager: rp.nsManager, baseURI: rp.baseURI, prefixConflictPolicy: rp.prefixConflictPolicy}
+	err = tp.parseDocument()
+	if err != nil {
+		return err
+	}
+
+	embedded := make(map[string]bool)
+	entities := make([]*Entity, 0, len(tp.subjects))
+	for _, subject := range tp.subjects {
+		if subject.kind == rdfBlankNode && tp.blankNodeObjects[subject.value] {
+			continue
+		}
+		entity, err := rp.buildEntity(tp, subject, make(map[string]bool), embedded)
+		if err != nil {
+			return err
+		}
+		entities = append(entities, entity)
+	}
+
+	// blank nodes that are only objects of each other can not be reached from any entity, this is checked before
+	// emitting so an invalid document emits nothing
+	for _, subject := range tp.subjects {
+		if subject.kind == rdfBlankNode && !embedded[subject.value] && tp.blankNodeObjects[subject.value] {
+			return errors.New("parsing error: blank nodes that form a cycle cannot be embedded")
+		}
+	}
+
+	for _, entity := range entities {
+		err = emitEntity(entity)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rp *RDFParser) buildEntity(tp *turtleParser, subject rdfTerm, blankNodesInPath map[string]bool, embedded map[string]bool) (*Entity, error) {
+	entity := NewEntity()
+	if subject.kind == rdfIRI {
+		id, err := rp.identifier(subject.value)
+		if err != nil {
+			return nil, err
+		}
+		entity.ID = id
+	} else {
+		if blankNodesInPath[subject.value] {
+			return nil, errors.New("parsing error: blank nodes that form a cycle cannot be embedded")
+		}
+		blankNodesInPath[subject.value] = true
+		defer delete(blankNodesInPath, subject.value)
+	}
+
+	for _, triple := range tp.triplesBySubject[subject.key()] {
+		predicate, err := rp.identifier(triple.predicate.value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch triple.object.kind {
+		case rdfIRI:
+			ref, err := rp.identifier(triple.object.value)
+			if err != nil {
+				return nil, err
+			}
+			switch v := entity.References[predicate].(type) {
+			case nil:
+				entity.References[predicate] = ref
+			case string:
+				entity.References[predicate] = []string{v, ref}
+			case []string:
+				entity.References[predicate] = append(v, ref)
+			}
+		case rdfBlankNode:
+			embeddedEntity, err := rp.buildEntity(tp, triple.object, blankNodesInPath, embedded)
+			if err != nil {
+				return nil, err
+			}
+			embedded[triple.object.value] = true
+			addPropertyValue(entity, predicate, embeddedEntity)
+		case rdfLiteral:
+			addPropertyValue(entity, predicate, literalValue(triple.object))
+		}
+	}
+
+	return entity, nil
+}
+
+func (rp *RDFParser) identifier(iri string) (string, error) {
+	if rp.compressURIs {
+		return compressIdentifier(rp.nsManager, iri)
+	}
+	return iri, nil
+}
+
+func addPropertyValue(entity *Entity, predicate string, value any) {
+	existing, found := entity.Properties[predicate]
+	if !found {
+		entity.Properties[predicate] = value
+		return
+	}
+	if values, ok := existing.([]any); ok {
+		entity.Properties[predicate] = append(values, value)
+		return
+	}
+	entity.Properties[predicate] = []any{existing, value}
+}
+
+// literalValue returns the Go value of a literal based on its datatype. Literals of unknown datatypes and values that
+// are not valid for their datatype are returned as JsonLdValue with the lexical form and the datatype.
+func literalValue(literal rdfTerm) any {
+	switch literal.datatype {
+	case xsdBoolean:
+		if v, err := strconv.ParseBool(literal.value); err == nil {
+			return v
+		}
+	case xsdInteger, XsdNamespaceExpansion + "int", XsdNamespaceExpansion + "long", XsdNamespaceExpansion + "short",
+		XsdNamespaceExpansion + "byte", XsdNamespaceExpansion + "nonNegativeInteger", XsdNamespaceExpansion + "positiveInteger",
+		XsdNamespaceExpansion + "nonPositiveInteger", XsdNamespaceExpansion + "negativeInteger",
+		XsdNamespaceExpansion + "unsignedInt", XsdNamespaceExpansion + "unsignedLong",
+		XsdNamespaceExpansion + "unsignedShort", XsdNamespaceExpansion + "unsignedByte":
+		if v, err := strconv.ParseInt(literal.value, 10, 64); err == nil {
+			return int(v)
+		}
+		// integers outside the range of int64 keep their digits so they are written back as xsd:integer
+		if isDecimalLexical(literal.value) && !strings.Contains(literal.value, ".") {
+			return json.Number(strings.TrimPrefix(literal.value, "+"))
+		}
+	case xsdDecimal:
+		if isDecimalLexical(literal.value) {
+			return Decimal(literal.value)
+		}
+	case xsdDouble, XsdNamespaceExpansion + "float":
+		if v, err := strconv.ParseFloat(literal.value, 64); err == nil {
+			return v
+		}
+		switch literal.value {
+		case "INF", "+INF":
+			return math.Inf(1)
+		case "-INF":
+			return math.Inf(-1)
+		case "NaN":
+			return math.NaN()
+		}
+	case xsdDateTime:
+		if v, err := time.Parse(time.RFC3339Nano, literal.value); err == nil {
+			return DateTime{v}
+		}
+	case xsdDate:
+		if v, err := parseDate(literal.value); err == nil {
+			return Date{v}
+		}
+	case xsdBase64Binary:
+		if v, err := base64.StdEncoding.DecodeString(literal.value); err == nil {
+			return Binary(v)
+		}
+	case rdfLangString:
+		if literal.language != "" {
+			return LangString{Value: literal.value, Language: literal.language}
+		}
+	}
+	if literal.datatype == "" || literal.datatype == xsdString {
+		return literal.value
+	}
+	// literals of other datatypes, and values that are not valid for their datatype, keep the datatype
+	return JsonLdValue{Value: literal.value, Type: literal.datatype}
+}
+
+type rdfTermKind int
+
+const (
+	rdfIRI rdfTermKind = iota
+	rdfBlankNode
+	rdfLiteral
+)
+
+type rdfTerm struct {
+	kind     rdfTermKind
+	value    string
+	datatype string
+	language string
+}
+
+func (term rdfTerm) key() string {
+	if term.kind == rdfBlankNode {
+		return "_:" + term.value
+	}
+	return term.value
+}
+
+type rdfTriple struct {
+	subject   rdfTerm
+	predicate rdfTerm
+	object    rdfTerm
+}
+
+// turtleParser is a recursive descent parser for Turtle, which is a superset of N-Triples
+type turtleParser struct {
+	input     []rune
+	pos       int
+	line      int
+	nsManager NamespaceManager
+	baseURI   string
+	prefixes  map[string]string
+
+	prefixConflictPolicy PrefixConflictPolicy
+
+	blankNodeCount   int
+	subjects         []rdfTerm
+	triplesBySubject map[string][]rdfTriple
+	blankNodeObjects map[string]bool
+}
+
+func (tp *turtleParser) parseDocument() error {
+	tp.prefixes = make(map[string]string)
+	tp.triplesBySubject = make(map[string][]rdfTriple)
+	tp.blankNodeObjects = make(map[string]bool)
+
+	for {
+		tp.skipWhitespace()
+		if tp.atEnd() {
+			return nil
+		}
+		err := tp.parseStatement()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (tp *turtleParser) parseStatement() error {
+	if tp.peek() == '@' {
+		return tp.parseDirective()
+	}
+	if tp.matchKeyword("PREFIX", true) || tp.matchKeyword("BASE", true) {
+		return tp.parseSparqlDirective()
+	}
+
+	var subject rdfTerm
+	var err error
+	if tp.peek() == '[' {
+		subject, err = tp.parseBlankNodePropertyList()
+		if err != nil {
+			return err
+		}
+		tp.skipWhitespace()
+		if tp.peek() != '.' {
+			err = tp.parsePredicateObjectList(subject)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		subject, err = tp.parseSubject()
+		if err != nil {
+			return err
+		}
+		err = tp.parsePredicateObjectList(subject)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tp.expect('.')
+}
+
+func (tp *turtleParser) parseDirective() error {
+	tp.pos++
+	name := tp.readWhile(func(r rune) bool { return unicode.IsLetter(r) })
+	switch name {
+	case "prefix":
+		err := tp.parsePrefixDeclaration()
+		if err != nil {
+			return err
+		}
+	case "base":
+		err := tp.parseBaseDeclaration()
+		if err != nil {
+			return err
+		}
+	default:
+		return tp.errorf("unknown directive @%s", name)
+	}
+	return tp.expect('.')
+}
+
+func (tp *turtleParser) parseSparqlDirective() error {
+	name := strings.ToUpper(tp.readWhile(func(r rune) bool { return unicode.IsLetter(r) }))
+	if name == "PREFIX" {
+		return tp.parsePrefixDeclaration()
+	}
+	return tp.parseBaseDeclaration()
+}
+
+func (tp *turtleParser) parsePrefixDeclaration() error {
+	tp.skipWhitespace()
+	prefix := tp.readWhile(isPrefixNameChar)
+	if tp.peek() != ':' {
+		return tp.errorf("expected : after prefix name")
+	}
+	tp.pos++
+	tp.skipWhitespace()
+	expansion, err := tp.parseIRIRef()
+	if err != nil {
+		return err
+	}
+	tp.prefixes[prefix] = expansion
+
+	// the empty prefix is stored as the default namespace
+	if prefix == "" {
+		prefix = "_"
+	}
+	_, err = storePrefixExpansionMappingWithPolicy(tp.nsManager, prefix, expansion, tp.prefixConflictPolicy)
+	if err != nil {
+		return fmt.Errorf("parsing error: line %d: unable to store namespace mapping: %w", tp.line, err)
+	}
+	return nil
+}
+
+func (tp *turtleParser) parseBaseDeclaration() error {
+	tp.skipWhitespace()
+	base, err := tp.parseIRIRef()
+	if err != nil {
+		return err
+	}
+	tp.baseURI = base
+	return nil
+}
+
+func (tp *turtleParser) parseSubject() (rdfTerm, error) {
+	switch tp.peek() {
+	case '<':
+		iri, err := tp.parseIRIRef()
+		return rdfTerm{kind: rdfIRI, value: iri}, err
+	case '_':
+		return tp.parseBlankNodeLabel()
+	case '(':
+		return rdfTerm{}, tp.errorf("collections are not supported")
+	}
+	iri, err := tp.parsePrefixedName()
+	return rdfTerm{kind: rdfIRI, value: iri}, err
+}
+
+func (tp *turtleParser) parsePredicateObjectList(subject rdfTerm) error {
+	for {
+		tp.skipWhitespace()
+		predicate, err := tp.parseVerb()
+		if err != nil {
+			return err
+		}
+		err = tp.parseObjectList(subject, predicate)
+		if err != nil {
+			return err
+		}
+
+		// a predicate object list may have repeated and trailing semicolons
+		tp.skipWhitespace()
+		if tp.peek() != ';' {
+			return nil
+		}
+		for tp.peek() == ';' {
+			tp.pos++
+			tp.skipWhitespace()
+		}
+		if r := tp.peek(); r == '.' || r == ']' || tp.atEnd() {
+			return nil
+		}
+	}
+}
+
+func (tp *turtleParser) parseVerb() (rdfTerm, error) {
+	if tp.matchKeyword("a", false) {
+		tp.pos++
+		return rdfTerm{kind: rdfIRI, value: RdfTypeURI}, nil
+	}
+	if tp.peek() == '<' {
+		iri, err := tp.parseIRIRef()
+		return rdfTerm{kind: rdfIRI, value: iri}, err
+	}
+	iri, err := tp.parsePrefixedName()
+	return rdfTerm{kind: rdfIRI, value: iri}, err
+}
+
+func (tp *turtleParser) parseObjectList(subject rdfTerm, predicate rdfTerm) error {
+	for {
+		tp.skipWhitespace()
+		object, err := tp.parseObject()
+		if err != nil {
+			return err
+		}
+		tp.addTriple(subject, predicate, object)
+
+		tp.skipWhitespace()
+		if tp.peek() != ',' {
+			return nil
+		}
+		tp.pos++
+	}
+}
+
+func (tp *turtleParser) parseObject() (rdfTerm, error) {
+	r := tp.peek()
+	switch {
+	case r == '<':
+		iri, err := tp.parseIRIRef()
+		return rdfTerm{kind: rdfIRI, value: iri}, err
+	case r == '_':
+		return tp.parseBlankNodeLabel()
+	case r == '[':
+		return tp.parseBlankNodePropertyList()
+	case r == '(':
+		return rdfTerm{}, tp.errorf("collections are not supported")
+	case r == '"' || r == '\'':
+		return tp.parseRDFLiteral()
+	case r == '+' || r == '-' || r == '.' || unicode.IsDigit(r):
+		return tp.parseNumericLiteral()
+	case tp.matchKeyword("true", false):
+		tp.pos += 4
+		return rdfTerm{kind: rdfLiteral, value: "true", datatype: xsdBoolean}, nil
+	case tp.matchKeyword("false", false):
+		tp.pos += 5
+		return rdfTerm{kind: rdfLiteral, value: "false", datatype: xsdBoolean}, nil
+	}
+	iri, err := tp.parsePrefixedName()
+	return rdfTerm{kind: rdfIRI, value: iri}, err
+}
+
+func (tp *turtleParser) parseBlankNodePropertyList() (rdfTerm, error) {
+	tp.pos++
+	node := tp.newBlankNode()
+	tp.skipWhitespace()
+	if tp.peek() == ']' {
+		tp.pos++
+		return node, nil
+	}
+	err := tp.parsePredicateObjectList(node)
+	if err != nil {
+		return rdfTerm{}, err
+	}
+	return node, tp.expect(']')
+}
+
+func (tp *turtleParser) parseBlankNodeLabel() (rdfTerm, error) {
+	if !strings.HasPrefix(string(tp.input[tp.pos:min(tp.pos+2, len(tp.input))]), "_:") {
+		return rdfTerm{}, tp.errorf("expected blank node label")
+	}
+	tp.pos += 2
+	label := tp.readLocalName()
+	if label == "" {
+		return rdfTerm{}, tp.errorf("empty blank node label")
+	}
+	// labels in the document are kept apart from the generated labels of anonymous blank nodes
+	return rdfTerm{kind: rdfBlankNode, value: "l" + label}, nil
+}
+
+func (tp *turtleParser) newBlankNode() rdfTerm {
+	tp.blankNodeCount++
+	return rdfTerm{kind: rdfBlankNode, value: fmt.Sprintf("g%d", tp.blankNodeCount)}
+}
+
+func (tp *turtleParser) addTriple(subject rdfTerm, predicate rdfTerm, object rdfTerm) {
+	key := subject.key()
+	if _, found := tp.triplesBySubject[key]; !found {
+		tp.subjects = append(tp.subjects, subject)
+	}
+	tp.triplesBySubject[key] = append(tp.triplesBySubject[key], rdfTriple{subject: subject, predicate: predicate, object: object})
+	if object.kind == rdfBlankNode {
+		tp.blankNodeObjects[object.value] = true
+	}
+}
+
+func (tp *turtleParser) parseIRIRef() (string, error) {
+	if tp.peek() != '<' {
+		return "", tp.errorf("expected <")
+	}
+	tp.pos++
+	builder := strings.Builder{}
+	for {
+		if tp.atEnd() {
+			return "", tp.errorf("unterminated IRI")
+		}
+		r := tp.input[tp.pos]
+		tp.pos++
+		switch {
+		case r == '>':
+			return tp.resolveIRI(builder.String()), nil
+		case r == '\\':
+			escaped, err := tp.parseUnicodeEscape()
+			if err != nil {
+				return "", err
+			}
+			builder.WriteRune(escaped)
+		case r <= 0x20 || r == '<' || r == '"' || r == '{' || r == '}' || r == '|' || r == '^' || r == '`':
+			return "", tp.errorf("invalid character %q in IRI", r)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+}
+
+func (tp *turtleParser) resolveIRI(iri string) string {
+	if tp.baseURI == "" {
+		return iri
+	}
+	reference, err := url.Parse(iri)
+	if err != nil || reference.IsAbs() {
+		return iri
+	}
+	base, err := url.Parse(tp.baseURI)
+	if err != nil {
+		return iri
+	}
+	return base.ResolveReference(reference).String()
+}
+
+func (tp *turtleParser) parsePrefixedName() (string, error) {
+	prefix := tp.readWhile(isPrefixNameChar)
+	if tp.peek() != ':' {
+		return "", tp.errorf("unexpected character %q", tp.peek())
+	}
+	tp.pos++
+	expansion, found := tp.prefixes[prefix]
+	if !found {
+		return "", tp.errorf("undeclared prefix %s", prefix)
+	}
+	return expansion + tp.readLocalName(), nil
+}
+
+// readLocalName reads the local part of a prefixed name or blank node label, a trailing . is left as the end of statement
+func (tp *turtleParser) readLocalName() string {
+	builder := strings.Builder{}
+	for !tp.atEnd() {
+		r := tp.input[tp.pos]
+		if r == '\\' && tp.pos+1 < len(tp.input) {
+			builder.WriteRune(tp.input[tp.pos+1])
+			tp.pos += 2
+			continue
+		}
+		if !isPrefixNameChar(r) && r != ':' && r != '%' {
+			break
+		}
+		builder.WriteRune(r)
+		tp.pos++
+	}
+	local := builder.String()
+	for strings.HasSuffix(local, ".") {
+		local = local[:len(local)-1]
+		tp.pos--
+	}
+	return local
+}
+
+func (tp *turtleParser) parseRDFLiteral() (rdfTerm, error) {
+	lexical, err := tp.parseString()
+	if err != nil {
+		return rdfTerm{}, err
+	}
+	literal := rdfTerm{kind: rdfLiteral, value: lexical, datatype: xsdString}
+
+	switch {
+	case tp.peek() == '@':
+		tp.pos++
+		literal.language = tp.readWhile(func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' })
+		if literal.language == "" {
+			return rdfTerm{}, tp.errorf("empty language tag")
+		}
+		literal.datatype = rdfLangString
+	case tp.peek() == '^':
+		if tp.pos+1 >= len(tp.input) || tp.input[tp.pos+1] != '^' {
+			return rdfTerm{}, tp.errorf("expected ^^")
+		}
+		tp.pos += 2
+		if tp.peek() == '<' {
+			literal.datatype, err = tp.parseIRIRef()
+		} else {
+			literal.datatype, err = tp.parsePrefixedName()
+		}
+		if err != nil {
+			return rdfTerm{}, err
+		}
+	}
+	return literal, nil
+}
+
+func (tp *turtleParser) parseString() (string, error) {
+	quote := tp.input[tp.pos]
+	long := tp.pos+2 < len(tp.input) && tp.input[tp.pos+1] == quote && tp.input[tp.pos+2] == quote
+	if long {
+		tp.pos += 3
+	} else {
+		tp.pos++
+	}
+
+	builder := strings.Builder{}
+	for {
+		if tp.atEnd() {
+			return "", tp.errorf("unterminated string")
+		}
+		r := tp.input[tp.pos]
+		switch {
+		case r == quote && !long:
+			tp.pos++
+			return builder.String(), nil
+		case r == quote && long && tp.pos+2 < len(tp.input) && tp.input[tp.pos+1] == quote && tp.input[tp.pos+2] == quote:
+			tp.pos += 3
+			return builder.String(), nil
+		case r == '\\':
+			tp.pos++
+			escaped, err := tp.parseStringEscape()
+			if err != nil {
+				return "", err
+			}
+			builder.WriteRune(escaped)
+		case (r == '\n' || r == '\r') && !long:
+			return "", tp.errorf("line break in string")
+		default:
+			if r == '\n' {
+				tp.line++
+			}
+			builder.WriteRune(r)
+			tp.pos++
+		}
+	}
+}
+
+func (tp *turtleParser) parseStringEscape() (rune, error) {
+	if tp.atEnd() {
+		return 0, tp.errorf("unterminated escape sequence")
+	}
+	r := tp.input[tp.pos]
+	switch r {
+	case 'u', 'U':
+		return tp.parseUnicodeEscape()
+	}
+	tp.pos++
+	switch r {
+	case 't':
+		return '\t', nil
+	case 'b':
+		return '\b', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 'f':
+		return '\f', nil
+	case '"', '\'', '\\':
+		return r, nil
+	}
+	return 0, tp.errorf("invalid escape sequence \\%c", r)
+}
+
+// parseUnicodeEscape parses the uXXXX or UXXXXXXXX part of a unicode escape sequence
+func (tp *turtleParser) parseUnicodeEscape() (rune, error) {
+	if tp.atEnd() {
+		return 0, tp.errorf("unterminated escape sequence")
+	}
+	length := 0
+	switch tp.input[tp.pos] {
+	case 'u':
+		length = 4
+	case 'U':
+		length = 8
+	default:
+		return 0, tp.errorf("invalid escape sequence \\%c", tp.input[tp.pos])
+	}
+	if tp.pos+1+length > len(tp.input) {
+		return 0, tp.errorf("unterminated escape sequence")
+	}
+	code, err := strconv.ParseUint(string(tp.input[tp.pos+1:tp.pos+1+length]), 16, 32)
+	if err != nil {
+		return 0, tp.errorf("invalid unicode escape sequence")
+	}
+	tp.pos += 1 + length
+	return rune(code), nil
+}
+
+func (tp *turtleParser) parseNumericLiteral() (rdfTerm, error) {
+	lexical := tp.readWhile(func(r rune) bool {
+		return unicode.IsDigit(r) || r == '+' || r == '-' || r == '.' || r == 'e' || r == 'E'
+	})
+	// the last . terminates the statement unless followed by digits
+	for strings.HasSuffix(lexical, ".") {
+		lexical = lexical[:len(lexical)-1]
+		tp.pos--
+	}
+
+	datatype := xsdInteger
+	if strings.ContainsAny(lexical, "eE") {
+		datatype = xsdDouble
+	} else if strings.Contains(lexical, ".") {
+		datatype = xsdDecimal
+	}
+	if _, err := strconv.ParseFloat(lexical, 64); err != nil {
+		return rdfTerm{}, tp.errorf("invalid numeric literal %s", lexical)
+	}
+	return rdfTerm{kind: rdfLiteral, value: lexical, datatype: datatype}, nil
+}
+
+func (tp *turtleParser) skipWhitespace() {
+	for !tp.atEnd() {
+		r := tp.input[tp.pos]
+		if r == '#' {
+			for !tp.atEnd() && tp.input[tp.pos] != '\n' {
+				tp.pos++
+			}
+			continue
+		}
+		if !unicode.IsSpace(r) {
+			return
+		}
+		if r == '\n' {
+			tp.line++
+		}
+		tp.pos++
+	}
+}
+
+func (tp *turtleParser) expect(r rune) error {
+	tp.skipWhitespace()
+	if tp.peek() != r {
+		if tp.atEnd() {
+			return tp.errorf("expected %c but reached end of document", r)
+		}
+		return tp.errorf("expected %c but found %c", r, tp.peek())
+	}
+	tp.pos++
+	return nil
+}
+
+func (tp *turtleParser) matchKeyword(keyword string, ignoreCase bool) bool {
+	end := tp.pos + len(keyword)
+	if end > len(tp.input) {
+		return false
+	}
+	word := string(tp.input[tp.pos:end])
+	if word != keyword && !(ignoreCase && strings.EqualFold(word, keyword)) {
+		return false
+	}
+	return end == len(tp.input) || !isPrefixNameChar(tp.input[end]) && tp.input[end] != ':'
+}
+
+func (tp *turtleParser) readWhile(accept func(rune) bool) string {
+	start := tp.pos
+	for !tp.atEnd() && accept(tp.input[tp.pos]) {
+		tp.pos++
+	}
+	return string(tp.input[start:tp.pos])
+}
+
+func (tp *turtleParser) peek() rune {
+	if tp.atEnd() {
+		return 0
+	}
+	return tp.input[tp.pos]
+}
+
+func (tp *turtleParser) atEnd() bool {
+	return tp.pos >= len(tp.input)
+}
+
+func (tp *turtleParser) errorf(format string, args ...any) error {
+	return errors.New("parsing error: line " + strconv.Itoa(tp.line) + ": " + fmt.Sprintf(format, args...))
+}
+
+func isPrefixNameChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
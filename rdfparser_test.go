@@ -0,0 +1,348 @@
+package egdm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTurtle(t *testing.T) {
+	reader := strings.NewReader(`
+		# people
+		@prefix ex: <http://example.com/> .
+		PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+		@base <http://example.com/people/> .
+
+		<1> a ex:Person, ex:Agent ;
+			ex:name "John Smith" ;
+			ex:nickname "Johnny"@en ;
+			ex:age 42 ;
+			ex:height 1.85 ;
+			ex:weight 8.1e1 ;
+			ex:active true ;
+			ex:score "7"^^xsd:int ;
+			ex:bio """multi
+line""" ;
+			ex:address [
+				ex:street 'Main Street' ;
+				ex:country ex:norway
+			] ;
+			ex:knows <2> .
+
+		<2> ex:name "Jane \"JJ\" Smith" ;
+			ex:knows <1>, _:b1 .
+
+		_:b1 ex:name "Anonymous" .
+		[ ex:name "Standalone" ] .
+	`)
+
+	nsManager := NewNamespaceContext()
+	ec, err := NewRDFParser(nsManager).LoadEntityCollection(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ec.Entities) != 3 {
+		t.Fatalf("expected 3 entities, got %d", len(ec.Entities))
+	}
+
+	john := ec.Entities[0]
+	if john.ID != "http://example.com/people/1" {
+		t.Errorf("expected id to be resolved against base, got %s", john.ID)
+	}
+	types, err := john.GetReferenceValues(RdfTypeURI)
+	if err != nil || len(types) != 2 || types[0] != "http://example.com/Person" || types[1] != "http://example.com/Agent" {
+		t.Errorf("unexpected types %v", types)
+	}
+	if john.Properties["http://example.com/name"] != "John Smith" {
+		t.Errorf("unexpected name %v", john.Properties["http://example.com/name"])
+	}
+	if john.Properties["http://example.com/nickname"] != (LangString{Value: "Johnny", Language: "en"}) {
+		t.Errorf("unexpected nickname %v", john.Properties["http://example.com/nickname"])
+	}
+	if john.Properties["http://example.com/age"] != 42 {
+		t.Errorf("unexpected age %v", john.Properties["http://example.com/age"])
+	}
+	if john.Properties["http://example.com/height"] != Decimal("1.85") {
+		t.Errorf("unexpected height %v", john.Properties["http://example.com/height"])
+	}
+	if john.Properties["http://example.com/weight"] != 81.0 {
+		t.Errorf("unexpected weight %v", john.Properties["http://example.com/weight"])
+	}
+	if john.Properties["http://example.com/active"] != true {
+		t.Errorf("unexpected active %v", john.Properties["http://example.com/active"])
+	}
+	if john.Properties["http://example.com/score"] != 7 {
+		t.Errorf("unexpected score %v", john.Properties["http://example.com/score"])
+	}
+	if john.Properties["http://example.com/bio"] != "multi\nline" {
+		t.Errorf("unexpected bio %v", john.Properties["http://example.com/bio"])
+	}
+	address, ok := john.Properties["http://example.com/address"].(*Entity)
+	if !ok {
+		t.Fatalf("expected embedded address entity, got %T", john.Properties["http://example.com/address"])
+	}
+	if address.ID != "" || address.Properties["http://example.com/street"] != "Main Street" || address.References["http://example.com/country"] != "http://example.com/norway" {
+		t.Errorf("unexpected address %v", address)
+	}
+
+	jane := ec.Entities[1]
+	if jane.Properties["http://example.com/name"] != "Jane \"JJ\" Smith" {
+		t.Errorf("unexpected name %v", jane.Properties["http://example.com/name"])
+	}
+	if jane.References["http://example.com/knows"] != "http://example.com/people/1" {
+		t.Errorf("unexpected knows %v", jane.References["http://example.com/knows"])
+	}
+	anonymous, ok := jane.Properties["http://example.com/knows"].(*Entity)
+	if !ok || anonymous.Properties["http://example.com/name"] != "Anonymous" {
+		t.Errorf("expected labelled blank node to be embedded, got %v", jane.Properties["http://example.com/knows"])
+	}
+
+	if ec.Entities[2].ID != "" || ec.Entities[2].Properties["http://example.com/name"] != "Standalone" {
+		t.Errorf("expected standalone blank node entity, got %v", ec.Entities[2])
+	}
+
+	if expansion, _ := nsManager.GetNamespaceExpansionForPrefix("ex"); expansion != "http://example.com/" {
+		t.Errorf("expected ex prefix to be registered, got %s", expansion)
+	}
+	if expansion, _ := nsManager.GetNamespaceExpansionForPrefix("xsd"); expansion != XsdNamespaceExpansion {
+		t.Errorf("expected xsd prefix to be registered, got %s", expansion)
+	}
+}
+
+func TestParseNTriplesRoundTrip(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	entity := NewEntity().SetID("ex:1")
+	entity.SetProperty("ex:name", "John\nSmith")
+	entity.SetProperty("ex:age", 42)
+	entity.SetProperty("ex:height", 1.85)
+	entity.SetProperty("ex:tags", []any{"a", "b"})
+	entity.SetProperty("ex:address", NewEntity().SetProperty("ex:street", "Main Street"))
+	entity.SetReference("ex:knows", []string{"ex:2", "ex:3"})
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteNTriples(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewRDFParser(NewNamespaceContext()).WithCompressURIs().LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Entities) != 1 {
+		t.Fatalf("expected 1 entity, got %d", len(parsed.Entities))
+	}
+	result := parsed.Entities[0]
+	if result.ID != "ns0:1" {
+		t.Errorf("unexpected id %s", result.ID)
+	}
+	if result.Properties["ns0:name"] != "John\nSmith" || result.Properties["ns0:age"] != 42 || result.Properties["ns0:height"] != 1.85 {
+		t.Errorf("unexpected properties %v", result.Properties)
+	}
+	if tags, ok := result.Properties["ns0:tags"].([]any); !ok || len(tags) != 2 {
+		t.Errorf("unexpected tags %v", result.Properties["ns0:tags"])
+	}
+	if address, ok := result.Properties["ns0:address"].(*Entity); !ok || address.Properties["ns0:street"] != "Main Street" {
+		t.Errorf("unexpected address %v", result.Properties["ns0:address"])
+	}
+	if refs, err := result.GetReferenceValues("ns0:knows"); err != nil || len(refs) != 2 || refs[0] != "ns0:2" {
+		t.Errorf("unexpected refs %v", refs)
+	}
+}
+
+func TestParseTurtleRoundTrip(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	nsManager.StorePrefixExpansionMapping("rdf", RdfNamespaceExpansion)
+
+	entity := NewEntity().SetID("ex:1")
+	entity.SetProperty("ex:name", "John Smith")
+	entity.SetProperty("ex:height", 1.85)
+	entity.SetProperty("ex:address", NewEntity().SetProperty("ex:street", "Main Street"))
+	entity.SetReference("rdf:type", "ex:Person")
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteTurtle(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := NewRDFParser(nil).LoadEntityCollection(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := parsed.Entities[0]
+	if result.ID != "http://example.com/1" || result.References[RdfTypeURI] != "http://example.com/Person" || result.Properties["http://example.com/height"] != 1.85 {
+		t.Errorf("unexpected entity %v", result)
+	}
+	if address, ok := result.Properties["http://example.com/address"].(*Entity); !ok || address.Properties["http://example.com/street"] != "Main Street" {
+		t.Errorf("unexpected address %v", result.Properties["http://example.com/address"])
+	}
+}
+
+func TestParseRDFLiteralTypesRoundTrip(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+
+	born := time.Date(1981, 5, 17, 8, 0, 0, 0, time.UTC)
+	entity := NewEntity().SetID("ex:1")
+	entity.SetProperty("ex:price", Decimal("1.10"))
+	entity.SetProperty("ex:name", LangString{Value: "Jean", Language: "fr"})
+	entity.SetProperty("ex:born", DateTime{born})
+	entity.SetProperty("ex:day", Date{born.Truncate(24 * time.Hour)})
+	entity.SetProperty("ex:data", Binary{1, 2, 3})
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+
+	writers := map[string]func(io.Writer) error{
+		"N-Triples": ec.WriteNTriples,
+		"Turtle":    ec.WriteTurtle,
+	}
+	for name, write := range writers {
+		buffer := bytes.Buffer{}
+		if err := write(&buffer); err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := NewRDFParser(nil).LoadEntityCollection(&buffer)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		props := parsed.Entities[0].Properties
+		if props["http://example.com/price"] != Decimal("1.10") {
+			t.Errorf("%s: expected decimal to keep its lexical form, got %#v", name, props["http://example.com/price"])
+		}
+		if props["http://example.com/name"] != (LangString{Value: "Jean", Language: "fr"}) {
+			t.Errorf("%s: expected language tagged string, got %#v", name, props["http://example.com/name"])
+		}
+		if born, ok := props["http://example.com/born"].(DateTime); !ok || !born.Equal(entity.Properties["ex:born"].(DateTime).Time) {
+			t.Errorf("%s: unexpected dateTime %#v", name, props["http://example.com/born"])
+		}
+		if _, ok := props["http://example.com/day"].(Date); !ok {
+			t.Errorf("%s: unexpected date %#v", name, props["http://example.com/day"])
+		}
+		if data, ok := props["http://example.com/data"].(Binary); !ok || !bytes.Equal(data, []byte{1, 2, 3}) {
+			t.Errorf("%s: unexpected binary %#v", name, props["http://example.com/data"])
+		}
+	}
+}
+
+func TestParseNTriplesWithCompressURIsAndNonHierarchicalIRIs(t *testing.T) {
+	reader := strings.NewReader(`<urn:uuid:1234> <http://example.com/knows> <mailto:jane@example.com> .
+`)
+
+	ec, err := NewRDFParser(NewNamespaceContext()).WithCompressURIs().LoadEntityCollection(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity := ec.Entities[0]
+	if entity.ID != "urn:uuid:1234" {
+		t.Errorf("expected urn to be kept as it is, got %s", entity.ID)
+	}
+	if entity.References["ns0:knows"] != "mailto:jane@example.com" {
+		t.Errorf("unexpected references %v", entity.References)
+	}
+}
+
+func TestParseTurtleErrors(t *testing.T) {
+	documents := []string{
+		`ex:1 ex:name "John" .`,
+		`<http://example.com/1> <http://example.com/name> "John"`,
+		`<http://example.com/1> <http://example.com/name> "John .`,
+		`<http://example.com/1> <http://example.com/list> ( 1 2 ) .`,
+		`_:a <http://example.com/p> _:b . _:b <http://example.com/p> _:a .`,
+	}
+
+	for _, document := range documents {
+		_, err := NewRDFParser(nil).LoadEntityCollection(strings.NewReader(document))
+		if err == nil {
+			t.Errorf("expected error for document %s", document)
+		}
+	}
+}
+
+func TestParseRDFIntegersOutsideInt64(t *testing.T) {
+	document := `<http://example.com/1> <http://example.com/serial> "18446744073709551615"^^<http://www.w3.org/2001/XMLSchema#unsignedLong> .
+<http://example.com/1> <http://example.com/big> "-99999999999999999999"^^<http://www.w3.org/2001/XMLSchema#integer> .
+`
+	ec, err := NewRDFParser(nil).LoadEntityCollection(strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := ec.Entities[0].Properties
+	if props["http://example.com/serial"] != json.Number("18446744073709551615") {
+		t.Errorf("expected unsignedLong to keep its digits, got %#v", props["http://example.com/serial"])
+	}
+	if props["http://example.com/big"] != json.Number("-99999999999999999999") {
+		t.Errorf("expected integer to keep its digits, got %#v", props["http://example.com/big"])
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteNTriples(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), `"-99999999999999999999"^^<http://www.w3.org/2001/XMLSchema#integer>`) {
+		t.Errorf("expected integer to be written back as xsd:integer, got %s", buffer.String())
+	}
+}
+
+func TestParseRDFWithBlankNodeCycleEmitsNothing(t *testing.T) {
+	document := `<http://example.com/1> <http://example.com/name> "John" .
+_:a <http://example.com/p> _:b .
+_:b <http://example.com/p> _:a .
+`
+	emitted := 0
+	err := NewRDFParser(nil).Parse(strings.NewReader(document), func(*Entity) error {
+		emitted++
+		return nil
+	}, func(*Continuation) {})
+	if err == nil {
+		t.Fatal("expected error for blank nodes that form a cycle")
+	}
+	if emitted != 0 {
+		t.Errorf("expected no entities to be emitted before the error, got %d", emitted)
+	}
+}
+
+func TestParseNTriplesKeepsUnknownDatatypes(t *testing.T) {
+	data := `<http://example.com/1> <http://example.com/custom> "abc"^^<http://example.com/Custom> .
+<http://example.com/1> <http://example.com/age> "old"^^<http://www.w3.org/2001/XMLSchema#integer> .
+`
+	ec, err := NewRDFParser(NewNamespaceContext()).LoadEntityCollection(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := ec.Entities[0].Properties
+	if props["http://example.com/custom"] != (JsonLdValue{Value: "abc", Type: "http://example.com/Custom"}) {
+		t.Errorf("expected the datatype to be kept, got %#v", props["http://example.com/custom"])
+	}
+	if props["http://example.com/age"] != (JsonLdValue{Value: "old", Type: xsdInteger}) {
+		t.Errorf("expected the datatype of an invalid value to be kept, got %#v", props["http://example.com/age"])
+	}
+
+	// the datatypes are written back out
+	buffer := bytes.Buffer{}
+	if err := ec.WriteNTriples(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != `<http://example.com/1> <http://example.com/age> "old"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.com/1> <http://example.com/custom> "abc"^^<http://example.com/Custom> .
+` {
+		t.Errorf("unexpected n-triples output:\n%s", buffer.String())
+	}
+}
@@ -0,0 +1,109 @@
+package egdm
+
+import "fmt"
+
+// rewriteEntity returns a copy of the entity where the id, the property and reference keys, the reference values and
+// the identifiers of embedded entities are replaced by the result of the rewrite function. The entity is not changed.
+func rewriteEntity(entity *Entity, rewrite func(identifier string) (string, error)) (*Entity, error) {
+	result := NewEntity()
+	result.InternalID = entity.InternalID
+	result.Recorded = entity.Recorded
+	result.IsDeleted = entity.IsDeleted
+	result.Extensions = entity.Extensions
+
+	if entity.ID != "" {
+		id, err := rewrite(entity.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.ID = id
+	}
+
+	// the keys are visited in order so that rewrites that generate prefixes are deterministic
+	for _, key := range sortedKeys(entity.References) {
+		value := entity.References[key]
+		newKey, err := rewrite(key)
+		if err != nil {
+			return nil, err
+		}
+		newValue, err := rewriteReferenceValue(value, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		result.References[newKey] = newValue
+	}
+
+	for _, key := range sortedKeys(entity.Properties) {
+		value := entity.Properties[key]
+		newKey, err := rewrite(key)
+		if err != nil {
+			return nil, err
+		}
+		newValue, err := rewritePropertyValue(value, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		result.Properties[newKey] = newValue
+	}
+
+	return result, nil
+}
+
+func rewriteReferenceValue(value any, rewrite func(identifier string) (string, error)) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return rewrite(v)
+	case []string:
+		refs := make([]string, len(v))
+		for i, ref := range v {
+			newRef, err := rewrite(ref)
+			if err != nil {
+				return nil, err
+			}
+			refs[i] = newRef
+		}
+		return refs, nil
+	case []any:
+		refs := make([]any, len(v))
+		for i, ref := range v {
+			newRef, err := rewriteReferenceValue(ref, rewrite)
+			if err != nil {
+				return nil, err
+			}
+			refs[i] = newRef
+		}
+		return refs, nil
+	}
+	return nil, fmt.Errorf("unexpected type %T in refs", value)
+}
+
+// rewritePropertyValue rewrites the embedded entities in a property value, other values are returned as they are
+func rewritePropertyValue(value any, rewrite func(identifier string) (string, error)) (any, error) {
+	switch v := value.(type) {
+	case *Entity:
+		return rewriteEntity(v, rewrite)
+	case map[string]any:
+		return rewriteEntity(newEntityFromMap(v), rewrite)
+	case []*Entity:
+		entities := make([]*Entity, len(v))
+		for i, entity := range v {
+			newEntity, err := rewriteEntity(entity, rewrite)
+			if err != nil {
+				return nil, err
+			}
+			entities[i] = newEntity
+		}
+		return entities, nil
+	case []any:
+		values := make([]any, len(v))
+		for i, val := range v {
+			newValue, err := rewritePropertyValue(val, rewrite)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = newValue
+		}
+		return values, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,216 @@
+package egdm
+
+// The traversal functions follow the references of the entities in an EntityCollection. Reference values are read with
+// Entity.GetReferenceValues, and ids and reference types are compared by full URI so that CURIE and full URI forms
+// match. References to ids that are not in the collection are not followed. When no reference types are given all
+// references are followed.
+
+// GetOutgoingNeighbours returns the entities referenced by the entity with the id
+func (ec *EntityCollection) GetOutgoingNeighbours(id string, referenceTypes ...string) []*Entity {
+	entity := ec.GetEntity(id)
+	if entity == nil {
+		return nil
+	}
+
+	filter := ec.referenceTypeFilter(referenceTypes)
+	seen := make(map[string]bool)
+	var neighbours []*Entity
+	for _, ref := range ec.referencedIDs(entity, filter) {
+		key := ec.indexKey(ref)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if neighbour := ec.GetEntity(ref); neighbour != nil {
+			neighbours = append(neighbours, neighbour)
+		}
+	}
+	return neighbours
+}
+
+// GetIncomingNeighbours returns the entities that reference the entity with the id. The references of every entity
+// in the collection are read, there is no index of incoming references.
+func (ec *EntityCollection) GetIncomingNeighbours(id string, referenceTypes ...string) []*Entity {
+	target := ec.indexKey(id)
+	filter := ec.referenceTypeFilter(referenceTypes)
+	var neighbours []*Entity
+	for _, entity := range ec.Entities {
+		if entity.ID == "" {
+			continue
+		}
+		for _, ref := range ec.referencedIDs(entity, filter) {
+			if ec.indexKey(ref) == target {
+				neighbours = append(neighbours, entity)
+				break
+			}
+		}
+	}
+	return neighbours
+}
+
+// TraverseBreadthFirst visits the entities reachable from the seed ids in breadth first order. Each entity is visited
+// once with its distance from the nearest seed. Entities further than maxDepth from the seeds are not visited, a
+// negative maxDepth has no limit. The traversal stops when visit returns false.
+func (ec *EntityCollection) TraverseBreadthFirst(seedIDs []string, maxDepth int, visit func(entity *Entity, depth int) bool, referenceTypes ...string) {
+	type step struct {
+		entity *Entity
+		depth  int
+	}
+
+	filter := ec.referenceTypeFilter(referenceTypes)
+	visited := make(map[string]bool)
+	var queue []step
+	for _, id := range seedIDs {
+		if entity := ec.GetEntity(id); entity != nil && !visited[ec.indexKey(id)] {
+			visited[ec.indexKey(id)] = true
+			queue = append(queue, step{entity, 0})
+		}
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if !visit(current.entity, current.depth) {
+			return
+		}
+		if maxDepth >= 0 && current.depth >= maxDepth {
+			continue
+		}
+		for _, ref := range ec.referencedIDs(current.entity, filter) {
+			key := ec.indexKey(ref)
+			if visited[key] {
+				continue
+			}
+			if neighbour := ec.GetEntity(ref); neighbour != nil {
+				visited[key] = true
+				queue = append(queue, step{neighbour, current.depth + 1})
+			}
+		}
+	}
+}
+
+// TraverseDepthFirst visits the entities reachable from the seed ids in depth first order. Each entity is visited once
+// with the depth at which it was first reached. Entities deeper than maxDepth are not visited, a negative maxDepth has
+// no limit. An entity reached again by a shorter path is expanded again, so that entities within maxDepth of the seeds
+// are visited whichever path reaches them first. The traversal stops when visit returns false.
+func (ec *EntityCollection) TraverseDepthFirst(seedIDs []string, maxDepth int, visit func(entity *Entity, depth int) bool, referenceTypes ...string) {
+	filter := ec.referenceTypeFilter(referenceTypes)
+	// depths holds the smallest depth each entity has been reached at
+	depths := make(map[string]int)
+
+	var traverse func(entity *Entity, depth int, isFirstReach bool) bool
+	traverse = func(entity *Entity, depth int, isFirstReach bool) bool {
+		if isFirstReach && !visit(entity, depth) {
+			return false
+		}
+		if maxDepth >= 0 && depth >= maxDepth {
+			return true
+		}
+		for _, ref := range ec.referencedIDs(entity, filter) {
+			key := ec.indexKey(ref)
+			reachedDepth, reached := depths[key]
+			if reached && reachedDepth <= depth+1 {
+				continue
+			}
+			if neighbour := ec.GetEntity(ref); neighbour != nil {
+				depths[key] = depth + 1
+				if !traverse(neighbour, depth+1, !reached) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	for _, id := range seedIDs {
+		key := ec.indexKey(id)
+		reachedDepth, reached := depths[key]
+		if reached && reachedDepth == 0 {
+			continue
+		}
+		if entity := ec.GetEntity(id); entity != nil {
+			depths[key] = 0
+			if !traverse(entity, 0, !reached) {
+				return
+			}
+		}
+	}
+}
+
+// GetShortestPath returns the entities on a shortest path of references from the entity with the from id to the
+// entity with the to id, including both. It returns nil if there is no such path.
+func (ec *EntityCollection) GetShortestPath(fromID string, toID string, referenceTypes ...string) []*Entity {
+	target := ec.indexKey(toID)
+	previous := make(map[*Entity]*Entity)
+	var found *Entity
+
+	ec.TraverseBreadthFirst([]string{fromID}, -1, func(entity *Entity, depth int) bool {
+		if ec.indexKey(entity.ID) == target {
+			found = entity
+			return false
+		}
+		// record the predecessor of the neighbours that have not been reached yet
+		for _, neighbour := range ec.GetOutgoingNeighbours(entity.ID, referenceTypes...) {
+			if _, reached := previous[neighbour]; !reached {
+				previous[neighbour] = entity
+			}
+		}
+		return true
+	}, referenceTypes...)
+
+	if found == nil {
+		return nil
+	}
+
+	from := ec.GetEntity(fromID)
+	path := []*Entity{found}
+	for current := found; current != from; {
+		current = previous[current]
+		path = append(path, current)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// GetReachableSubgraph returns a collection with the entities reachable from the seed ids within maxDepth references,
+// in breadth first order, a negative maxDepth has no limit. The returned collection shares the namespace manager and
+// the entities of this collection.
+func (ec *EntityCollection) GetReachableSubgraph(seedIDs []string, maxDepth int, referenceTypes ...string) *EntityCollection {
+	subgraph := NewEntityCollection(ec.NamespaceManager)
+	ec.TraverseBreadthFirst(seedIDs, maxDepth, func(entity *Entity, depth int) bool {
+		_ = subgraph.AddEntity(entity)
+		return true
+	}, referenceTypes...)
+	return subgraph
+}
+
+// referenceTypeFilter returns the set of full URIs of the reference types, or nil if all types are followed
+func (ec *EntityCollection) referenceTypeFilter(referenceTypes []string) map[string]bool {
+	if len(referenceTypes) == 0 {
+		return nil
+	}
+	filter := make(map[string]bool, len(referenceTypes))
+	for _, referenceType := range referenceTypes {
+		filter[ec.indexKey(referenceType)] = true
+	}
+	return filter
+}
+
+// referencedIDs returns the reference values of the entity for the reference types in the filter, in the order of the
+// reference types
+func (ec *EntityCollection) referencedIDs(entity *Entity, filter map[string]bool) []string {
+	var ids []string
+	for _, referenceType := range sortedKeys(entity.References) {
+		if filter != nil && !filter[ec.indexKey(referenceType)] {
+			continue
+		}
+		values, err := entity.GetReferenceValues(referenceType)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, values...)
+	}
+	return ids
+}
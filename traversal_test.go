@@ -0,0 +1,163 @@
+package egdm
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTraversalCollection returns a collection with the graph
+// 1 -knows-> 2, 1 -worksFor-> 3, 2 -knows-> [4, 5], 4 -knows-> 1, 5 -knows-> missing
+func newTraversalCollection(t *testing.T) *EntityCollection {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	ec := NewEntityCollection(nsManager)
+	for _, entity := range []*Entity{
+		NewEntity().SetID("ex:1").SetReference("ex:knows", "ex:2").SetReference("http://example.com/worksFor", []string{"ex:3"}),
+		NewEntity().SetID("ex:2").SetReference("ex:knows", []any{"http://example.com/4", "ex:5"}),
+		NewEntity().SetID("ex:3"),
+		NewEntity().SetID("ex:4").SetReference("ex:knows", "ex:1"),
+		NewEntity().SetID("ex:5").SetReference("ex:knows", "ex:missing"),
+	} {
+		if err := ec.AddEntity(entity); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ec
+}
+
+func entityIDs(entities []*Entity) []string {
+	result := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		result = append(result, entity.ID)
+	}
+	return result
+}
+
+func TestGetReferenceValuesFromAnySlice(t *testing.T) {
+	entity := NewEntity().SetReference("ex:knows", []any{"ex:1", "ex:2"})
+	values, err := entity.GetReferenceValues("ex:knows")
+	if err != nil || !reflect.DeepEqual(values, []string{"ex:1", "ex:2"}) {
+		t.Errorf("unexpected reference values %v %v", values, err)
+	}
+}
+
+func TestNeighbours(t *testing.T) {
+	ec := newTraversalCollection(t)
+
+	if got := entityIDs(ec.GetOutgoingNeighbours("ex:1")); !reflect.DeepEqual(got, []string{"ex:2", "ex:3"}) {
+		t.Errorf("unexpected outgoing neighbours %v", got)
+	}
+	if got := entityIDs(ec.GetOutgoingNeighbours("http://example.com/1", "ex:worksFor")); !reflect.DeepEqual(got, []string{"ex:3"}) {
+		t.Errorf("unexpected outgoing neighbours by type %v", got)
+	}
+	if got := entityIDs(ec.GetOutgoingNeighbours("ex:5")); len(got) != 0 {
+		t.Errorf("expected missing entities to be skipped, got %v", got)
+	}
+	if got := entityIDs(ec.GetIncomingNeighbours("ex:1")); !reflect.DeepEqual(got, []string{"ex:4"}) {
+		t.Errorf("unexpected incoming neighbours %v", got)
+	}
+	if got := entityIDs(ec.GetIncomingNeighbours("ex:3", "ex:knows")); len(got) != 0 {
+		t.Errorf("unexpected incoming neighbours by type %v", got)
+	}
+}
+
+func TestTraverse(t *testing.T) {
+	ec := newTraversalCollection(t)
+
+	var visited []string
+	var depths []int
+	ec.TraverseBreadthFirst([]string{"ex:1"}, -1, func(entity *Entity, depth int) bool {
+		visited = append(visited, entity.ID)
+		depths = append(depths, depth)
+		return true
+	})
+	if !reflect.DeepEqual(visited, []string{"ex:1", "ex:2", "ex:3", "ex:4", "ex:5"}) || !reflect.DeepEqual(depths, []int{0, 1, 1, 2, 2}) {
+		t.Errorf("unexpected breadth first order %v %v", visited, depths)
+	}
+
+	visited = nil
+	ec.TraverseDepthFirst([]string{"ex:1"}, -1, func(entity *Entity, depth int) bool {
+		visited = append(visited, entity.ID)
+		return true
+	})
+	if !reflect.DeepEqual(visited, []string{"ex:1", "ex:2", "ex:4", "ex:5", "ex:3"}) {
+		t.Errorf("unexpected depth first order %v", visited)
+	}
+
+	visited = nil
+	ec.TraverseDepthFirst([]string{"ex:1"}, 1, func(entity *Entity, depth int) bool {
+		visited = append(visited, entity.ID)
+		return true
+	}, "ex:knows")
+	if !reflect.DeepEqual(visited, []string{"ex:1", "ex:2"}) {
+		t.Errorf("unexpected depth limited traversal %v", visited)
+	}
+
+	visited = nil
+	ec.TraverseBreadthFirst([]string{"ex:1"}, -1, func(entity *Entity, depth int) bool {
+		visited = append(visited, entity.ID)
+		return len(visited) < 2
+	})
+	if len(visited) != 2 {
+		t.Errorf("expected traversal to stop, visited %v", visited)
+	}
+}
+
+func TestTraverseDepthFirstWithConvergingPaths(t *testing.T) {
+	// A -> B -> D -> E and A -> D, D is first reached at depth 2 through B and then at depth 1 through A
+	ec := NewEntityCollection(nil)
+	for _, entity := range []*Entity{
+		NewEntity().SetID("http://example.com/A").SetReference("http://example.com/a", "http://example.com/B").SetReference("http://example.com/b", "http://example.com/D"),
+		NewEntity().SetID("http://example.com/B").SetReference("http://example.com/a", "http://example.com/D"),
+		NewEntity().SetID("http://example.com/D").SetReference("http://example.com/a", "http://example.com/E"),
+		NewEntity().SetID("http://example.com/E"),
+	} {
+		if err := ec.AddEntity(entity); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var visited []string
+	var depths []int
+	ec.TraverseDepthFirst([]string{"http://example.com/A"}, 2, func(entity *Entity, depth int) bool {
+		visited = append(visited, entity.ID)
+		depths = append(depths, depth)
+		return true
+	})
+	expected := []string{"http://example.com/A", "http://example.com/B", "http://example.com/D", "http://example.com/E"}
+	if !reflect.DeepEqual(visited, expected) || !reflect.DeepEqual(depths, []int{0, 1, 2, 2}) {
+		t.Errorf("expected every entity within the max depth to be visited once, got %v %v", visited, depths)
+	}
+}
+
+func TestGetShortestPath(t *testing.T) {
+	ec := newTraversalCollection(t)
+
+	if got := entityIDs(ec.GetShortestPath("ex:1", "http://example.com/5")); !reflect.DeepEqual(got, []string{"ex:1", "ex:2", "ex:5"}) {
+		t.Errorf("unexpected path %v", got)
+	}
+	if got := entityIDs(ec.GetShortestPath("ex:4", "ex:3")); !reflect.DeepEqual(got, []string{"ex:4", "ex:1", "ex:3"}) {
+		t.Errorf("unexpected path %v", got)
+	}
+	if got := ec.GetShortestPath("ex:4", "ex:3", "ex:knows"); got != nil {
+		t.Errorf("expected no path with knows references, got %v", entityIDs(got))
+	}
+	if got := ec.GetShortestPath("ex:3", "ex:1"); got != nil {
+		t.Errorf("expected no path, got %v", entityIDs(got))
+	}
+	if got := entityIDs(ec.GetShortestPath("ex:1", "ex:1")); !reflect.DeepEqual(got, []string{"ex:1"}) {
+		t.Errorf("unexpected path to self %v", got)
+	}
+}
+
+func TestGetReachableSubgraph(t *testing.T) {
+	ec := newTraversalCollection(t)
+
+	subgraph := ec.GetReachableSubgraph([]string{"ex:2", "ex:3"}, 1)
+	if got := entityIDs(subgraph.Entities); !reflect.DeepEqual(got, []string{"ex:2", "ex:3", "ex:4", "ex:5"}) {
+		t.Errorf("unexpected subgraph %v", got)
+	}
+	if subgraph.NamespaceManager != ec.NamespaceManager || !subgraph.HasEntity("http://example.com/4") {
+		t.Errorf("expected subgraph to share the namespace manager")
+	}
+}
@@ -0,0 +1,337 @@
+package egdm
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TurtleWriter writes an EntityCollection as Turtle. Prefixes are declared from the namespace mappings of the
+// collection, all predicates of an entity are grouped under one subject, anonymous embedded entities are written
+// as blank node property lists and rdf:type references are written with 'a'. Prefixes, predicates and objects are
+// sorted so that the output is deterministic.
+type TurtleWriter struct {
+	prefixes []turtlePrefix
+	lookup   []turtlePrefix
+}
+
+type turtlePrefix struct {
+	prefix    string
+	expansion string
+}
+
+func NewTurtleWriter() *TurtleWriter {
+	return &TurtleWriter{}
+}
+
+func (turtleWriter *TurtleWriter) Write(ec *EntityCollection, writer io.Writer) error {
+	turtleWriter.prefixes = makeTurtlePrefixes(ec.NamespaceManager.GetNamespaceMappings())
+
+	// IRIs are written with the prefix of the longest matching expansion
+	turtleWriter.lookup = make([]turtlePrefix, len(turtleWriter.prefixes))
+	copy(turtleWriter.lookup, turtleWriter.prefixes)
+	sort.SliceStable(turtleWriter.lookup, func(i, j int) bool {
+		return len(turtleWriter.lookup[i].expansion) > len(turtleWriter.lookup[j].expansion)
+	})
+
+	// write prefixes
+	for _, p := range turtleWriter.prefixes {
+		_, err := writer.Write([]byte("@prefix " + p.prefix + ": " + formatIRI(p.expansion) + " .\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	// collect the named entities, embedded entities with an identity are written as subjects in their own right
+	entities := make([]*Entity, 0, len(ec.Entities))
+	for _, entity := range ec.Entities {
+		entities = append(entities, entity)
+		entities = appendNamedEmbeddedEntities(entities, entity)
+	}
+
+	for _, entity := range entities {
+		block, err := turtleWriter.formatSubject(ec.NamespaceManager, entity)
+		if err != nil {
+			return err
+		}
+		if block == "" {
+			continue
+		}
+		_, err = writer.Write([]byte("\n" + block + " .\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (turtleWriter *TurtleWriter) formatSubject(nsManager NamespaceManager, entity *Entity) (string, error) {
+	predicates, err := turtleWriter.formatPredicateObjectList(nsManager, entity, "    ")
+	if err != nil {
+		return "", err
+	}
+	if len(predicates) == 0 {
+		return "", nil
+	}
+
+	if entity.ID == "" {
+		return "[\n    " + strings.Join(predicates, " ;\n    ") + "\n]", nil
+	}
+
+	id, err := nsManager.GetFullURI(entity.ID)
+	if err != nil {
+		return "", err
+	}
+	return turtleWriter.formatIRI(id) + " " + strings.Join(predicates, " ;\n    "), nil
+}
+
+// formatPredicateObjectList returns one 'predicate object, object' entry per predicate of the entity
+func (turtleWriter *TurtleWriter) formatPredicateObjectList(nsManager NamespaceManager, entity *Entity, indent string) ([]string, error) {
+	objects := make(map[string][]string)
+
+	for key, value := range entity.References {
+		predicate, err := nsManager.GetFullURI(key)
+		if err != nil {
+			return nil, err
+		}
+		values, err := referenceValues(value)
+		if err != nil {
+			return nil, err
+		}
+		for _, val := range values {
+			ref, err := nsManager.GetFullURI(val)
+			if err != nil {
+				return nil, err
+			}
+			objects[predicate] = append(objects[predicate], turtleWriter.formatIRI(ref))
+		}
+	}
+
+	for key, value := range entity.Properties {
+		predicate, err := nsManager.GetFullURI(key)
+		if err != nil {
+			return nil, err
+		}
+		values, err := turtleWriter.formatPropertyValue(nsManager, value, indent)
+		if err != nil {
+			return nil, err
+		}
+		objects[predicate] = append(objects[predicate], values...)
+	}
+
+	predicates := make([]string, 0, len(objects))
+	for predicate := range objects {
+		predicates = append(predicates, predicate)
+	}
+	sort.Slice(predicates, func(i, j int) bool {
+		// rdf:type is written first
+		if predicates[i] == RdfTypeURI || predicates[j] == RdfTypeURI {
+			return predicates[i] == RdfTypeURI
+		}
+		return predicates[i] < predicates[j]
+	})
+
+	result := make([]string, 0, len(predicates))
+	for _, predicate := range predicates {
+		values := objects[predicate]
+		if len(values) == 0 {
+			continue
+		}
+		sort.Strings(values)
+		if predicate == RdfTypeURI {
+			result = append(result, "a "+strings.Join(values, ", "))
+		} else {
+			result = append(result, turtleWriter.formatIRI(predicate)+" "+strings.Join(values, ", "))
+		}
+	}
+
+	return result, nil
+}
+
+func (turtleWriter *TurtleWriter) formatPropertyValue(nsManager NamespaceManager, value any, indent string) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, val := range v {
+			values, err := turtleWriter.formatPropertyValue(nsManager, val, indent)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, values...)
+		}
+		return result, nil
+	case []string:
+		result := make([]string, 0, len(v))
+		for _, val := range v {
+			result = append(result, "\""+escapeLiteral(val)+"\"")
+		}
+		return result, nil
+	case []*Entity:
+		result := make([]string, 0, len(v))
+		for _, val := range v {
+			values, err := turtleWriter.formatPropertyValue(nsManager, val, indent)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, values...)
+		}
+		return result, nil
+	case map[string]any:
+		return turtleWriter.formatPropertyValue(nsManager, newEntityFromMap(v), indent)
+	case *Entity:
+		if v.ID != "" {
+			id, err := nsManager.GetFullURI(v.ID)
+			if err != nil {
+				return nil, err
+			}
+			return []string{turtleWriter.formatIRI(id)}, nil
+		}
+		predicates, err := turtleWriter.formatPredicateObjectList(nsManager, v, indent+"    ")
+		if err != nil {
+			return nil, err
+		}
+		if len(predicates) == 0 {
+			return []string{"[]"}, nil
+		}
+		separator := " ;\n" + indent + "    "
+		return []string{"[\n" + indent + "    " + strings.Join(predicates, separator) + "\n" + indent + "]"}, nil
+	case LangString:
+		return []string{formatLangLiteral(v)}, nil
+	case JsonLdValue:
+		lexical, datatype, err := jsonLdValueLiteral(nsManager, v)
+		if err != nil {
+			return nil, err
+		}
+		if v.Language != "" {
+			return []string{formatLangLiteral(LangString{Value: lexical, Language: v.Language})}, nil
+		}
+		return []string{turtleWriter.formatLiteral(lexical, datatype)}, nil
+	}
+
+	lexical, datatype, err := literalOf(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{turtleWriter.formatLiteral(lexical, datatype)}, nil
+}
+
+func (turtleWriter *TurtleWriter) formatLiteral(lexical string, datatype string) string {
+	switch datatype {
+	case xsdString:
+		return "\"" + escapeLiteral(lexical) + "\""
+	case xsdBoolean:
+		if lexical == "true" || lexical == "false" {
+			return lexical
+		}
+	case xsdInteger:
+		// values that are not valid integers are written as typed literals
+		if isDecimalLexical(lexical) && !strings.Contains(lexical, ".") {
+			return lexical
+		}
+	case xsdDouble:
+		// the turtle double syntax requires an exponent, NaN, infinity and values that are not valid doubles are written
+		// as typed literals
+		if isDoubleLexical(lexical) {
+			if strings.ContainsAny(lexical, "eE") {
+				return lexical
+			}
+			return lexical + "E0"
+		}
+	case xsdDecimal:
+		// the turtle decimal syntax requires a fraction
+		if isDecimalLexical(lexical) && !strings.HasSuffix(lexical, ".") && strings.Contains(lexical, ".") {
+			return lexical
+		}
+	}
+	return "\"" + escapeLiteral(lexical) + "\"^^" + turtleWriter.formatIRI(datatype)
+}
+
+// formatIRI writes the IRI as a prefixed name when a declared prefix matches and the local name is valid
+func (turtleWriter *TurtleWriter) formatIRI(iri string) string {
+	for _, p := range turtleWriter.lookup {
+		if strings.HasPrefix(iri, p.expansion) {
+			local := iri[len(p.expansion):]
+			if isTurtleLocalName(local) {
+				return p.prefix + ":" + local
+			}
+		}
+	}
+	return formatIRI(iri)
+}
+
+// makeTurtlePrefixes returns the prefixes that can be declared in Turtle, ordered by prefix. The default namespace
+// is declared as the empty prefix.
+func makeTurtlePrefixes(mappings map[string]string) []turtlePrefix {
+	prefixes := make([]turtlePrefix, 0, len(mappings))
+	for prefix, expansion := range mappings {
+		if prefix == "_" {
+			prefix = ""
+		} else if !isTurtlePrefixName(prefix) {
+			continue
+		}
+		prefixes = append(prefixes, turtlePrefix{prefix: prefix, expansion: expansion})
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].prefix < prefixes[j].prefix
+	})
+	return prefixes
+}
+
+func isTurtlePrefixName(prefix string) bool {
+	if prefix == "" || strings.HasSuffix(prefix, ".") {
+		return false
+	}
+	for i, r := range prefix {
+		if i == 0 && !unicode.IsLetter(r) {
+			return false
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func isTurtleLocalName(local string) bool {
+	if local == "" || strings.HasSuffix(local, ".") || strings.HasPrefix(local, ".") || strings.HasPrefix(local, "-") {
+		return false
+	}
+	for _, r := range local {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func appendNamedEmbeddedEntities(entities []*Entity, entity *Entity) []*Entity {
+	for _, key := range sortedKeys(entity.Properties) {
+		entities = appendNamedEmbeddedEntitiesFromValue(entities, entity.Properties[key])
+	}
+	return entities
+}
+
+func appendNamedEmbeddedEntitiesFromValue(entities []*Entity, value any) []*Entity {
+	switch v := value.(type) {
+	case []any:
+		for _, val := range v {
+			entities = appendNamedEmbeddedEntitiesFromValue(entities, val)
+		}
+	case []*Entity:
+		for _, val := range v {
+			entities = appendNamedEmbeddedEntitiesFromValue(entities, val)
+		}
+	case map[string]any:
+		entities = appendNamedEmbeddedEntitiesFromValue(entities, newEntityFromMap(v))
+	case *Entity:
+		if v.ID != "" {
+			entities = append(entities, v)
+		}
+		entities = appendNamedEmbeddedEntities(entities, v)
+	}
+	return entities
+}
@@ -0,0 +1,159 @@
+package egdm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTurtle(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("ex", "http://example.com/")
+	nsManager.StorePrefixExpansionMapping("people", "http://example.com/people/")
+	nsManager.StorePrefixExpansionMapping("rdf", "http://www.w3.org/1999/02/22-rdf-syntax-ns#")
+
+	address := NewEntity()
+	address.SetProperty("ex:street", "123 Main Street")
+	address.SetReference("ex:country", "ex:norway")
+
+	entity := NewEntity().SetID("people:1")
+	entity.SetProperty("ex:name", "John \"Johnny\" Smith")
+	entity.SetProperty("ex:active", true)
+	entity.SetProperty("ex:age", 42)
+	entity.SetProperty("ex:height", 1.85)
+	entity.SetProperty("ex:address", address)
+	entity.SetProperty("ex:employer", NewEntity().SetID("ex:acme").SetProperty("ex:name", "Acme"))
+	entity.SetReference("rdf:type", []string{"ex:Person", "ex:Agent"})
+	entity.SetReference("ex:knows", []any{"people:3", "http://example.com/people/2", "http://other.com/x y"})
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(entity); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.AddEntity(NewEntity().SetID("people:2").SetProperty("ex:name", "Jane")); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteTurtle(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `@prefix ex: <http://example.com/> .
+@prefix people: <http://example.com/people/> .
+@prefix rdf: <http://www.w3.org/1999/02/22-rdf-syntax-ns#> .
+
+people:1 a ex:Agent, ex:Person ;
+    ex:active true ;
+    ex:address [
+        ex:country ex:norway ;
+        ex:street "123 Main Street"
+    ] ;
+    ex:age 42 ;
+    ex:employer ex:acme ;
+    ex:height 1.85E0 ;
+    ex:knows <http://other.com/x\u0020y>, people:2, people:3 ;
+    ex:name "John \"Johnny\" Smith" .
+
+ex:acme ex:name "Acme" .
+
+people:2 ex:name "Jane" .
+`
+	if buffer.String() != expected {
+		t.Errorf("unexpected turtle output:\n%s", buffer.String())
+	}
+
+	// output is deterministic
+	for i := 0; i < 10; i++ {
+		again := bytes.Buffer{}
+		if err := ec.WriteTurtle(&again); err != nil {
+			t.Fatal(err)
+		}
+		if again.String() != buffer.String() {
+			t.Fatalf("expected identical output on repeated writes")
+		}
+	}
+}
+
+func TestWriteTurtleWithDefaultNamespaceAndAnonymousEntity(t *testing.T) {
+	nsManager := NewNamespaceContext()
+	nsManager.StorePrefixExpansionMapping("_", "http://example.com/")
+
+	ec := NewEntityCollection(nsManager)
+	if err := ec.AddEntity(NewEntity().SetProperty("name", "anonymous")); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteTurtle(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `@prefix : <http://example.com/> .
+
+[
+    :name "anonymous"
+] .
+`
+	if buffer.String() != expected {
+		t.Errorf("unexpected turtle output:\n%s", buffer.String())
+	}
+}
+
+func TestWriteTurtleDoubles(t *testing.T) {
+	turtleWriter := NewTurtleWriter()
+	literals := map[string]string{
+		"1.5":     "1.5E0",
+		"-2":      "-2E0",
+		"1e10":    "1e10",
+		".":       `"."^^<http://www.w3.org/2001/XMLSchema#double>`,
+		"-":       `"-"^^<http://www.w3.org/2001/XMLSchema#double>`,
+		"1.2.3":   `"1.2.3"^^<http://www.w3.org/2001/XMLSchema#double>`,
+		"--1":     `"--1"^^<http://www.w3.org/2001/XMLSchema#double>`,
+		"1e":      `"1e"^^<http://www.w3.org/2001/XMLSchema#double>`,
+		"NaN":     `"NaN"^^<http://www.w3.org/2001/XMLSchema#double>`,
+		"0x1p-2":  `"0x1p-2"^^<http://www.w3.org/2001/XMLSchema#double>`,
+		"Inf":     `"Inf"^^<http://www.w3.org/2001/XMLSchema#double>`,
+		"1.0e+10": "1.0e+10",
+	}
+	for lexical, expected := range literals {
+		if formatted := turtleWriter.formatLiteral(lexical, xsdDouble); formatted != expected {
+			t.Errorf("expected %s to be written as %s, got %s", lexical, expected, formatted)
+		}
+	}
+}
+
+func TestWriteTurtleIntegers(t *testing.T) {
+	turtleWriter := NewTurtleWriter()
+	literals := map[string]string{
+		"12":                   "12",
+		"-7":                   "-7",
+		"1.5":                  `"1.5"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+		"12 ; <x> <y>":         `"12 ; <x> <y>"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+		"":                     `""^^<http://www.w3.org/2001/XMLSchema#integer>`,
+		"99999999999999999999": "99999999999999999999",
+	}
+	for lexical, expected := range literals {
+		if formatted := turtleWriter.formatLiteral(lexical, xsdInteger); formatted != expected {
+			t.Errorf("expected %s to be written as %s, got %s", lexical, expected, formatted)
+		}
+	}
+}
+
+func TestWriteTurtleOfParsedCustomDatatypes(t *testing.T) {
+	data := `{"@context":{"ex":"http://example.com/"},"@id":"ex:1",
+		"ex:custom":{"@value":"abc","@type":"ex:Custom"},
+		"ex:lang":{"@value":5,"@language":"en"}}`
+	ec, err := NewJsonLDParser(NewNamespaceContext()).LoadEntityCollection(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := ec.WriteTurtle(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), `ex:custom "abc"^^ex:Custom`) || !strings.Contains(buffer.String(), `ex:lang "5"@en`) {
+		t.Errorf("unexpected turtle output:\n%s", buffer.String())
+	}
+}
@@ -0,0 +1,47 @@
+package egdm
+
+// WellKnownPrefixes holds the conventional prefixes of common vocabularies. It is used by
+// NewNamespaceContextWithDefaults and JsonLDWriter.WithWellKnownPrefixes. Entries can be added at start up, the table
+// must not be changed while it is in use.
+var WellKnownPrefixes = map[string]string{
+	"core":    CoreNamespaceExpansion,
+	"rdf":     RdfNamespaceExpansion,
+	"rdfs":    "http://www.w3.org/2000/01/rdf-schema#",
+	"xsd":     XsdNamespaceExpansion,
+	"owl":     "http://www.w3.org/2002/07/owl#",
+	"skos":    "http://www.w3.org/2004/02/skos/core#",
+	"schema":  "https://schema.org/",
+	"dcterms": "http://purl.org/dc/terms/",
+	"foaf":    "http://xmlns.com/foaf/0.1/",
+	"prov":    "http://www.w3.org/ns/prov#",
+}
+
+// NewNamespaceContextWithDefaults returns a NamespaceContext with the WellKnownPrefixes stored and the well known
+// prefixes preferred, see WithWellKnownPrefixPreference.
+func NewNamespaceContextWithDefaults() *NamespaceContext {
+	context := NewNamespaceContext().WithWellKnownPrefixPreference()
+	for prefix, expansion := range WellKnownPrefixes {
+		context.storePrefixExpansionMapping(prefix, expansion)
+	}
+	return context
+}
+
+// WithWellKnownPrefixPreference makes AssertPrefixedIdentifierFromURI use the conventional prefix from the
+// WellKnownPrefixes, if that prefix is not bound to something else, instead of a generated nsN prefix when it asserts
+// a prefix for a well known expansion. The well known prefixes are not stored until they are used.
+func (aContext *NamespaceContext) WithWellKnownPrefixPreference() *NamespaceContext {
+	aContext.lock.Lock()
+	defer aContext.lock.Unlock()
+	aContext.preferWellKnownPrefixes = true
+	return aContext
+}
+
+// wellKnownPrefixForExpansion returns the conventional prefix of the expansion
+func wellKnownPrefixForExpansion(expansion string) (string, bool) {
+	for prefix, wellKnownExpansion := range WellKnownPrefixes {
+		if wellKnownExpansion == expansion {
+			return prefix, true
+		}
+	}
+	return "", false
+}